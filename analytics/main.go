@@ -22,6 +22,16 @@ type Analytics struct {
 	totalDuration float64
 	botGames     int
 	pvpGames     int
+	botLevels    map[string]*LevelStats
+}
+
+// LevelStats tracks finished bot games at one difficulty. The human always
+// plays red against the bot.
+type LevelStats struct {
+	Games     int
+	HumanWins int
+	BotWins   int
+	Draws     int
 }
 
 func NewAnalytics() *Analytics {
@@ -34,7 +44,8 @@ func NewAnalytics() *Analytics {
 	})
 
 	return &Analytics{
-		reader: reader,
+		reader:    reader,
+		botLevels: make(map[string]*LevelStats),
 	}
 }
 
@@ -104,6 +115,10 @@ func (a *Analytics) processEvent(event map[string]interface{}) {
 		log.Printf("   Duration: %.2f seconds", duration)
 		log.Println("")
 
+		if event["is_bot"] == true {
+			a.recordBotResult(event["difficulty"], winner)
+		}
+
 		// Print statistics every 5 games
 		if a.gamesEnded%5 == 0 {
 			a.printStats()
@@ -111,6 +126,27 @@ func (a *Analytics) processEvent(event map[string]interface{}) {
 	}
 }
 
+func (a *Analytics) recordBotResult(difficulty, winner interface{}) {
+	level, _ := difficulty.(string)
+	if level == "" {
+		level = "unknown"
+	}
+	stats := a.botLevels[level]
+	if stats == nil {
+		stats = &LevelStats{}
+		a.botLevels[level] = stats
+	}
+	stats.Games++
+	switch winner {
+	case "red":
+		stats.HumanWins++
+	case "yellow":
+		stats.BotWins++
+	default:
+		stats.Draws++
+	}
+}
+
 func (a *Analytics) printStats() {
 	log.Println("📈 ===== STATISTICS =====")
 	log.Printf("   Total Games Started: %d", a.gamesStarted)
@@ -121,6 +157,11 @@ func (a *Analytics) printStats() {
 		avgDuration := a.totalDuration / float64(a.gamesEnded)
 		log.Printf("   Average Game Duration: %.2f seconds", avgDuration)
 	}
+	for level, stats := range a.botLevels {
+		humanWinRate := float64(stats.HumanWins) / float64(stats.Games) * 100
+		log.Printf("   Bot %s: %d games, human win rate %.1f%% (%d W / %d L / %d D)",
+			level, stats.Games, humanWinRate, stats.HumanWins, stats.BotWins, stats.Draws)
+	}
 	log.Println("========================")
 	log.Println("")
 }
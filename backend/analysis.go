@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"connect4/bot"
+	"connect4/engine"
+)
+
+const defaultAnalysisBudget = 500 * time.Millisecond
+
+// Move classifications. A move is "best" if no column scores higher, an
+// "inaccuracy" if it keeps the result but wins slower or loses faster, and a
+// "blunder" if it throws away a win or a draw. Moves whose position could not
+// be solved within the analysis budget are "unknown".
+const (
+	MoveBest       = "best"
+	MoveInaccuracy = "inaccuracy"
+	MoveBlunder    = "blunder"
+	MoveUnknown    = "unknown"
+)
+
+// MoveAnnotation is the verdict on one move of a finished game. Scores are
+// solver scores for the side that moved.
+type MoveAnnotation struct {
+	Ply        int    `json:"ply"`
+	Column     int    `json:"column"`
+	Color      Color  `json:"color"`
+	Class      string `json:"class"`
+	Score      int    `json:"score"`
+	BestColumn int    `json:"best_column"`
+	BestScore  int    `json:"best_score"`
+}
+
+// GameAnalysis annotates every move of a game. Accuracy is the share of each
+// player's classified moves that were best, counting inaccuracies as half.
+type GameAnalysis struct {
+	GameID   string            `json:"game_id"`
+	Moves    []MoveAnnotation  `json:"moves"`
+	Accuracy map[Color]float64 `json:"accuracy"`
+}
+
+// analysisQueueSize bounds the games waiting for analysis. Games that do
+// not fit are analysed when their analysis is next asked for.
+const analysisQueueSize = 64
+
+// analysisJob is a game queued for analysis, with its moves taken under the
+// game's lock.
+type analysisJob struct {
+	game     *GameState
+	moves    []int
+	imported bool
+}
+
+// classify rates the move played in col given the solver's scores for the
+// position. complete reports whether every legal move was scored.
+func classify(scores []engine.MoveScore, complete bool, col int) (string, int, engine.MoveScore) {
+	best := engine.MoveScore{Col: -1, Score: engine.MinScore - 1}
+	played, known := 0, false
+	for _, ms := range scores {
+		if ms.Score > best.Score {
+			best = ms
+		}
+		if ms.Col == col {
+			played, known = ms.Score, true
+		}
+	}
+	switch {
+	case !known:
+		return MoveUnknown, 0, best
+	case outcome(best.Score) > outcome(played):
+		return MoveBlunder, played, best
+	case !complete:
+		return MoveUnknown, played, best
+	case played == best.Score:
+		return MoveBest, played, best
+	}
+	return MoveInaccuracy, played, best
+}
+
+// outcome reduces a solver score to win (1), draw (0) or loss (-1).
+func outcome(score int) int {
+	switch {
+	case score > 0:
+		return 1
+	case score < 0:
+		return -1
+	}
+	return 0
+}
+
+// analyzeMoves solves the position before every move, giving each position
+// at most budget. It works backwards from the end so the transposition table
+// is warm by the time it reaches the harder, earlier positions.
+func analyzeMoves(gameID string, moves []int, budget time.Duration) *GameAnalysis {
+	positions := make([]*engine.Position, len(moves))
+	pos := engine.NewPosition()
+	for i, col := range moves {
+		positions[i] = pos.Clone()
+		if _, err := pos.Play(col); err != nil {
+			log.Printf("❌ Analysis of %s stopped at move %d: %v", gameID, i+1, err)
+			positions = positions[:i]
+			break
+		}
+	}
+
+	a := &GameAnalysis{GameID: gameID, Moves: make([]MoveAnnotation, len(positions))}
+	for i := len(positions) - 1; i >= 0; i-- {
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+		scores, err := bot.Analyze(ctx, positions[i])
+		cancel()
+		class, score, best := classify(scores, err == nil, moves[i])
+		a.Moves[i] = MoveAnnotation{
+			Ply: i + 1, Column: moves[i], Color: colorOf(positions[i].ToMove()),
+			Class: class, Score: score, BestColumn: best.Col, BestScore: best.Score,
+		}
+	}
+	a.Accuracy = accuracy(a.Moves)
+	return a
+}
+
+func accuracy(moves []MoveAnnotation) map[Color]float64 {
+	points := make(map[Color]float64)
+	counted := make(map[Color]int)
+	for _, m := range moves {
+		switch m.Class {
+		case MoveBest:
+			points[m.Color]++
+		case MoveInaccuracy:
+			points[m.Color] += 0.5
+		case MoveUnknown:
+			continue
+		}
+		counted[m.Color]++
+	}
+	acc := make(map[Color]float64)
+	for color, n := range counted {
+		acc[color] = float64(int(points[color]/float64(n)*1000+0.5)) / 10
+	}
+	return acc
+}
+
+// startAnalysis queues a game for analysis, unless it is analysed or
+// queued already. It reports false if the queue is full. The caller holds
+// game.mutex.
+func (gs *GameServer) startAnalysis(game *GameState) bool {
+	if game.Analysis != nil || game.analysing {
+		return true
+	}
+	moves := columns(game.Moves)
+	if len(moves) == 0 {
+		game.Analysis = &GameAnalysis{GameID: game.ID, Moves: []MoveAnnotation{}, Accuracy: map[Color]float64{}}
+		return true
+	}
+	select {
+	case gs.analyses <- analysisJob{game: game, moves: moves, imported: game.Imported}:
+		game.analysing = true
+		return true
+	default:
+		log.Printf("⚠ Analysis queue full - %s waits until it is asked for", game.ID)
+		return false
+	}
+}
+
+// runAnalysis analyses queued games one at a time, so finished games do not
+// starve the bots of live ones, stores the results and sends them to the
+// players.
+func (gs *GameServer) runAnalysis() {
+	for job := range gs.analyses {
+		start := time.Now()
+		a := analyzeMoves(job.game.ID, job.moves, gs.analysisBudget)
+		log.Printf("🔍 Analysed %s in %v - accuracy %v", job.game.ID, time.Since(start).Round(time.Millisecond), a.Accuracy)
+
+		if !job.imported {
+			gs.saveAnalysis(a)
+		}
+		game := job.game
+		game.mutex.Lock()
+		game.Analysis, game.analysing = a, false
+		msg := Message{Type: "analysis", GameID: game.ID, Analysis: a}
+		for _, p := range []*Player{game.Player1, game.Player2} {
+			if p != nil && p.Conn != nil && !p.Disconnected {
+				p.Conn.WriteJSON(msg)
+			}
+		}
+		game.mutex.Unlock()
+	}
+}
+
+func (gs *GameServer) saveAnalysis(a *GameAnalysis) {
+	if gs.db == nil {
+		return
+	}
+	tx, err := gs.db.Begin()
+	if err != nil {
+		log.Println("❌ Error saving analysis:", err)
+		return
+	}
+	defer tx.Rollback()
+	for _, m := range a.Moves {
+		_, err := tx.Exec(`
+			INSERT INTO move_analysis (game_id, ply, col, color, class, score, best_col, best_score)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (game_id, ply) DO NOTHING
+		`, a.GameID, m.Ply, m.Column, m.Color, m.Class, m.Score, m.BestColumn, m.BestScore)
+		if err != nil {
+			log.Println("❌ Error saving analysis:", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("❌ Error saving analysis:", err)
+	}
+}
+
+func (gs *GameServer) loadAnalysis(gameID string) (*GameAnalysis, error) {
+	rows, err := gs.db.Query(`
+		SELECT ply, col, color, class, score, best_col, best_score
+		FROM move_analysis WHERE game_id = $1 ORDER BY ply
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	a := &GameAnalysis{GameID: gameID}
+	for rows.Next() {
+		var m MoveAnnotation
+		if err := rows.Scan(&m.Ply, &m.Column, &m.Color, &m.Class, &m.Score, &m.BestColumn, &m.BestScore); err != nil {
+			return nil, err
+		}
+		a.Moves = append(a.Moves, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if a.Moves == nil {
+		return nil, sql.ErrNoRows
+	}
+	a.Accuracy = accuracy(a.Moves)
+	return a, nil
+}
+
+func (gs *GameServer) getAnalysis(w http.ResponseWriter, gameID string) {
+	gs.mutex.RLock()
+	game := gs.games[gameID]
+	gs.mutex.RUnlock()
+	if game != nil {
+		// Imports are analysed when first asked for, and games that did not
+		// fit in the queue are retried
+		game.mutex.Lock()
+		over, queued := game.Winner != "", true
+		if over || game.Imported {
+			queued = gs.startAnalysis(game)
+		}
+		a := game.Analysis
+		game.mutex.Unlock()
+		switch {
+		case a != nil:
+			json.NewEncoder(w).Encode(a)
+			return
+		case !over && !game.Imported:
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "game is still in progress"})
+			return
+		case !queued:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "analysis queue is full - try again later"})
+			return
+		}
+	}
+
+	if gs.db != nil {
+		a, err := gs.loadAnalysis(gameID)
+		if err == nil {
+			json.NewEncoder(w).Encode(a)
+			return
+		}
+		if err != sql.ErrNoRows {
+			log.Println("❌ Error loading analysis:", err)
+		}
+	}
+	if game != nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "pending"})
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": "analysis not found"})
+}
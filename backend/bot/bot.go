@@ -0,0 +1,90 @@
+// Package bot defines the computer opponents. Every strategy implements Bot
+// and registers a constructor under a name, so the server can pick one by
+// name and new strategies can be dropped in without touching the server.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"connect4/engine"
+)
+
+// Bot chooses moves for the side to move in a position.
+type Bot interface {
+	// Name identifies the strategy and its settings, e.g. "negamax-7".
+	Name() string
+	// ChooseMove returns a legal column, or -1 if there is none. The
+	// position must not be modified; clone it to explore. Bots should
+	// return their best move so far once ctx is done.
+	ChooseMove(ctx context.Context, pos *engine.Position) int
+}
+
+// Options tune a strategy. Zero values select the strategy's defaults.
+type Options struct {
+	// Depth is the search depth in plies for search-based bots.
+	Depth int
+	// Budget is the thinking time per move for time-limited bots.
+	Budget time.Duration
+	// Playouts caps the simulated games per move for sampling bots.
+	Playouts int
+}
+
+// Factory builds a new bot. Bots may keep per-game state, so each game gets
+// its own instance.
+type Factory func(Options) Bot
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a strategy available under name. It panics if the name is
+// taken, since that is a programming error.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("bot: Register called twice for " + name)
+	}
+	registry[name] = f
+}
+
+// New builds the strategy registered under name.
+func New(name string, opts Options) (Bot, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("bot: unknown strategy %q", name)
+	}
+	return f(opts), nil
+}
+
+// Names lists the registered strategies in alphabetical order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// immediateMove returns a column that wins for the side to move, or else one
+// that stops the opponent winning next turn, or -1.
+func immediateMove(b engine.Bitboard) int {
+	for _, side := range []engine.Disc{b.ToMove(), b.ToMove().Opponent()} {
+		for col := 0; col < engine.Cols; col++ {
+			if b.WinsWith(side, col) {
+				return col
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connect4/engine"
+)
+
+func position(t *testing.T, toMove engine.Disc, discs map[engine.Cell]engine.Disc) *engine.Position {
+	t.Helper()
+	var g engine.Grid
+	for cell, d := range discs {
+		g[cell.Row][cell.Col] = d
+	}
+	p, err := engine.FromCells(g, toMove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"random", "negamax", "mcts", "solver"} {
+		b, err := New(name, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if b.Name() == "" {
+			t.Errorf("%s: empty name", name)
+		}
+	}
+	if _, err := New("nope", Options{}); err == nil {
+		t.Error("unknown strategy should fail")
+	}
+	b, _ := New("negamax", Options{Depth: 3})
+	if b.Name() != "negamax-3" {
+		t.Errorf("name = %q, want negamax-3", b.Name())
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate Register should panic")
+		}
+	}()
+	Register("random", func(Options) Bot { return Random{} })
+}
+
+func TestBotsWinAndBlock(t *testing.T) {
+	win := position(t, engine.Yellow, map[engine.Cell]engine.Disc{
+		{Row: 5, Col: 0}: engine.Yellow, {Row: 5, Col: 1}: engine.Yellow, {Row: 5, Col: 2}: engine.Yellow,
+		{Row: 4, Col: 0}: engine.Red, {Row: 4, Col: 1}: engine.Red, {Row: 4, Col: 2}: engine.Red,
+	})
+	block := position(t, engine.Yellow, map[engine.Cell]engine.Disc{
+		{Row: 5, Col: 0}: engine.Red, {Row: 5, Col: 1}: engine.Red, {Row: 5, Col: 2}: engine.Red,
+	})
+	for _, name := range Names() {
+		b, err := New(name, Options{Depth: 4, Budget: 200 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if col := b.ChooseMove(context.Background(), win); col != 3 {
+			t.Errorf("%s should win at 3, got %d", b.Name(), col)
+		}
+		if col := b.ChooseMove(context.Background(), block); col != 3 {
+			t.Errorf("%s should block at 3, got %d", b.Name(), col)
+		}
+	}
+}
+
+func TestBotsPlayLegalMoves(t *testing.T) {
+	for _, name := range Names() {
+		b, _ := New(name, Options{Depth: 2, Budget: 20 * time.Millisecond})
+		p := engine.NewPosition()
+		for !p.IsOver() {
+			col := b.ChooseMove(context.Background(), p)
+			if _, err := p.Play(col); err != nil {
+				t.Fatalf("%s played illegal column %d after %v: %v", b.Name(), col, p.Moves(), err)
+			}
+		}
+		if col := b.ChooseMove(context.Background(), p); col != -1 {
+			t.Errorf("%s should return -1 when the game is over, got %d", b.Name(), col)
+		}
+	}
+}
+
+func TestSolverUsesBookAndBudget(t *testing.T) {
+	b := NewSolver(50 * time.Millisecond)
+	if col := b.ChooseMove(context.Background(), engine.NewPosition()); col != 3 {
+		t.Errorf("opening move should come from the book, got %d", col)
+	}
+
+	p := engine.NewPosition()
+	for _, col := range []int{0, 6} {
+		p.Play(col)
+	}
+	start := time.Now()
+	if col := b.ChooseMove(context.Background(), p); !p.CanPlay(col) {
+		t.Errorf("unsolved position should still get a legal move, got %d", col)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("solver took %v on a 50ms budget", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	if col := NewSolver(time.Minute).ChooseMove(ctx, p); !p.CanPlay(col) {
+		t.Errorf("cancelled search should still get a legal move, got %d", col)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("solver took %v after its context was cancelled", elapsed)
+	}
+
+	// Out of time, it still blocks a threat
+	p = engine.NewPosition()
+	for _, col := range []int{0, 6, 1, 6, 2} {
+		p.Play(col)
+	}
+	if col := NewSolver(time.Minute).ChooseMove(ctx, p); col != 3 {
+		t.Errorf("cancelled search should still block at 3, got %d", col)
+	}
+	if col := NewSolver(time.Nanosecond).ChooseMove(context.Background(), p); col != 3 {
+		t.Errorf("solver should block at 3 on a tiny budget, got %d", col)
+	}
+}
+
+func TestMCTSBeatsRandom(t *testing.T) {
+	mcts := NewMCTS(2000, time.Second)
+	wins := 0
+	for game := 0; game < 6; game++ {
+		red, yellow := Bot(mcts), Bot(Random{})
+		side := engine.Red
+		if game%2 == 1 {
+			red, yellow, side = yellow, red, engine.Yellow
+		}
+		winner, moves, err := PlayGame(context.Background(), red, yellow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if winner == side {
+			wins++
+		} else {
+			t.Logf("mcts did not win game %d: %v", game, moves)
+		}
+	}
+	if wins < 5 {
+		t.Errorf("mcts won only %d of 6 games against random", wins)
+	}
+}
+
+func TestMCTSConcurrentCalls(t *testing.T) {
+	mcts := NewMCTS(500, time.Second)
+	p := engine.NewPosition()
+	done := make(chan int)
+	for i := 0; i < 2; i++ {
+		go func() { done <- mcts.ChooseMove(context.Background(), p) }()
+	}
+	for i := 0; i < 2; i++ {
+		if col := <-done; !p.CanPlay(col) {
+			t.Errorf("mcts played illegal column %d", col)
+		}
+	}
+}
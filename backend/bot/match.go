@@ -0,0 +1,26 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"connect4/engine"
+)
+
+// PlayGame plays one game between two bots and returns the winner (Empty for
+// a draw) and the columns played. It is the building block for bot
+// tournaments.
+func PlayGame(ctx context.Context, red, yellow Bot) (engine.Disc, []int, error) {
+	pos := engine.NewPosition()
+	for !pos.IsOver() {
+		b := red
+		if pos.ToMove() == engine.Yellow {
+			b = yellow
+		}
+		col := b.ChooseMove(ctx, pos)
+		if _, err := pos.Play(col); err != nil {
+			return engine.Empty, pos.Moves(), fmt.Errorf("%s played column %d: %w", b.Name(), col, err)
+		}
+	}
+	return pos.Winner(), pos.Moves(), nil
+}
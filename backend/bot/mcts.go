@@ -0,0 +1,177 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"connect4/engine"
+)
+
+const (
+	defaultPlayouts   = 20000
+	defaultMCTSBudget = time.Second
+
+	// exploration is the UCT constant; sqrt(2) is the textbook choice for
+	// rewards in [0, 1].
+	exploration = math.Sqrt2
+)
+
+func init() {
+	Register("mcts", func(opts Options) Bot { return NewMCTS(opts.Playouts, opts.Budget) })
+}
+
+// MCTS is a Monte Carlo Tree Search bot using UCT selection and uniformly
+// random playouts. It stops at whichever comes first of its playout count
+// and its time budget, which makes it strong tactically but prone to
+// "human" strategic slips. Each call seeds its own random source, so
+// overlapping calls on one MCTS are safe.
+type MCTS struct {
+	Playouts int
+	Budget   time.Duration
+}
+
+// NewMCTS returns an MCTS bot; non-positive limits select the defaults.
+func NewMCTS(playouts int, budget time.Duration) *MCTS {
+	if playouts <= 0 {
+		playouts = defaultPlayouts
+	}
+	if budget <= 0 {
+		budget = defaultMCTSBudget
+	}
+	return &MCTS{Playouts: playouts, Budget: budget}
+}
+
+func (m *MCTS) Name() string { return fmt.Sprintf("mcts-%d", m.Playouts) }
+
+// mctsNode is reached by playing move; wins are counted for the side that
+// played it.
+type mctsNode struct {
+	parent   *mctsNode
+	move     int
+	children []*mctsNode
+	untried  []int
+	visits   int
+	wins     float64
+	terminal bool
+	result   float64 // reward for the side that played move, if terminal
+}
+
+func newNode(parent *mctsNode, move int, b *engine.Bitboard) *mctsNode {
+	n := &mctsNode{parent: parent, move: move}
+	for _, col := range engine.MoveOrder {
+		if b.CanPlay(col) {
+			n.untried = append(n.untried, col)
+		}
+	}
+	if len(n.untried) == 0 {
+		n.terminal, n.result = true, 0.5
+	}
+	return n
+}
+
+func (m *MCTS) ChooseMove(ctx context.Context, pos *engine.Position) int {
+	if pos.IsOver() {
+		return -1
+	}
+	b := pos.Bitboard()
+	if col := immediateMove(b); col != -1 {
+		return col
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Budget)
+	defer cancel()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	root := newNode(nil, -1, &b)
+	for i := 0; i < m.Playouts; i++ {
+		if i&0xff == 0 && ctx.Err() != nil {
+			break
+		}
+		iterate(rng, root, b)
+	}
+
+	if len(root.children) == 0 {
+		return root.untried[0]
+	}
+	best, bestVisits := -1, -1
+	for _, child := range root.children {
+		if child.visits > bestVisits {
+			best, bestVisits = child.move, child.visits
+		}
+	}
+	return best
+}
+
+// iterate runs one select, expand, playout and backpropagate cycle.
+func iterate(rng *rand.Rand, root *mctsNode, b engine.Bitboard) {
+	n := root
+	for !n.terminal && len(n.untried) == 0 {
+		n = n.selectChild()
+		b.Play(n.move)
+	}
+
+	if !n.terminal {
+		i := rng.Intn(len(n.untried))
+		col := n.untried[i]
+		n.untried = append(n.untried[:i], n.untried[i+1:]...)
+		wins := b.IsWinningMove(col)
+		b.Play(col)
+		child := newNode(n, col, &b)
+		if wins {
+			child.terminal, child.result = true, 1
+		}
+		n.children = append(n.children, child)
+		n = child
+	}
+
+	reward := n.result
+	if !n.terminal {
+		reward = playout(rng, b)
+	}
+	for ; n != nil; n = n.parent {
+		n.visits++
+		n.wins += reward
+		reward = 1 - reward
+	}
+}
+
+func (n *mctsNode) selectChild() *mctsNode {
+	var best *mctsNode
+	bestValue := math.Inf(-1)
+	logVisits := math.Log(float64(n.visits))
+	for _, child := range n.children {
+		value := child.wins/float64(child.visits) + exploration*math.Sqrt(logVisits/float64(child.visits))
+		if value > bestValue {
+			best, bestValue = child, value
+		}
+	}
+	return best
+}
+
+// playout plays random moves to the end and returns the reward for the side
+// that moved last before the playout started, i.e. the opponent of the side
+// to move in b.
+func playout(rng *rand.Rand, b engine.Bitboard) float64 {
+	mover := b.ToMove().Opponent()
+	var moves [engine.Cols]int
+	for !b.IsFull() {
+		count := 0
+		for col := 0; col < engine.Cols; col++ {
+			if b.CanPlay(col) {
+				moves[count] = col
+				count++
+			}
+		}
+		col := moves[rng.Intn(count)]
+		if b.IsWinningMove(col) {
+			if b.ToMove() == mover {
+				return 1
+			}
+			return 0
+		}
+		b.Play(col)
+	}
+	return 0.5
+}
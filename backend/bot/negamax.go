@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"connect4/engine"
+)
+
+const defaultDepth = 7
+
+func init() {
+	Register("negamax", func(opts Options) Bot { return NewNegamax(opts.Depth) })
+}
+
+// Negamax searches up to a fixed number of plies with alpha-beta pruning and
+// scores the leaves with engine.Evaluate. It deepens one ply at a time, so
+// when ctx ends it still has the move from the deepest finished search.
+type Negamax struct {
+	Depth int
+}
+
+// NewNegamax returns a search bot; depth <= 0 selects the default.
+func NewNegamax(depth int) *Negamax {
+	if depth <= 0 {
+		depth = defaultDepth
+	}
+	return &Negamax{Depth: depth}
+}
+
+func (n *Negamax) Name() string { return fmt.Sprintf("negamax-%d", n.Depth) }
+
+func (n *Negamax) ChooseMove(ctx context.Context, pos *engine.Position) int {
+	if pos.IsOver() {
+		return -1
+	}
+	b := pos.Bitboard()
+	best := -1
+	for depth := 1; depth <= n.Depth; depth++ {
+		col, _, err := engine.SearchContext(ctx, b, depth)
+		if err != nil {
+			break
+		}
+		best = col
+	}
+	if best == -1 {
+		return pos.LegalMoves()[0]
+	}
+	return best
+}
@@ -0,0 +1,32 @@
+package bot
+
+import (
+	"context"
+	"math/rand"
+
+	"connect4/engine"
+)
+
+func init() {
+	Register("random", func(Options) Bot { return Random{} })
+}
+
+// Random wins or blocks an immediate four, and otherwise plays a random
+// legal column.
+type Random struct{}
+
+func (Random) Name() string { return "random" }
+
+func (Random) ChooseMove(ctx context.Context, pos *engine.Position) int {
+	if pos.IsOver() {
+		return -1
+	}
+	if col := immediateMove(pos.Bitboard()); col != -1 {
+		return col
+	}
+	moves := pos.LegalMoves()
+	if len(moves) == 0 {
+		return -1
+	}
+	return moves[rand.Intn(len(moves))]
+}
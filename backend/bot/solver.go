@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"connect4/engine"
+)
+
+const (
+	defaultBudget = 2 * time.Second
+
+	// fallbackDepth is the search depth used when the solver cannot finish
+	// within its budget, which is typical in the first moves after the
+	// opening book.
+	fallbackDepth = 8
+
+	// tableBits sizes the transposition table shared by every solver bot:
+	// 2^22 slots of 8 bytes.
+	tableBits = 22
+)
+
+var sharedTable = sync.OnceValue(func() *engine.Table {
+	return engine.NewTable(tableBits)
+})
+
+func init() {
+	Register("solver", func(opts Options) Bot { return NewSolver(opts.Budget) })
+}
+
+// Analyze scores every legal move of pos exactly, sharing the solver bots'
+// transposition table. Like engine.Solver.Analyze it returns the moves
+// solved so far when ctx ends early.
+func Analyze(ctx context.Context, pos *engine.Position) ([]engine.MoveScore, error) {
+	return engine.NewSolver(sharedTable()).Analyze(ctx, pos.Bitboard())
+}
+
+// Solver plays perfectly whenever it can prove the result within its time
+// budget, using the opening book for the first plies. When time runs out it
+// keeps any proven win, avoids proven losses, and otherwise trusts a
+// fixed-depth search.
+type Solver struct {
+	Budget time.Duration
+}
+
+// NewSolver returns a solver bot; budget <= 0 selects the default.
+func NewSolver(budget time.Duration) *Solver {
+	if budget <= 0 {
+		budget = defaultBudget
+	}
+	return &Solver{Budget: budget}
+}
+
+func (s *Solver) Name() string { return "solver" }
+
+func (s *Solver) ChooseMove(ctx context.Context, pos *engine.Position) int {
+	if pos.IsOver() {
+		return -1
+	}
+	b := pos.Bitboard()
+	if col, ok := engine.BookMove(b); ok {
+		return col
+	}
+	if col := immediateMove(b); col != -1 {
+		return col
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Budget)
+	defer cancel()
+
+	// Deepen the fallback search as Negamax does, so running out of time
+	// still leaves the last completed depth's move
+	fallback := pos.LegalMoves()[0]
+	for depth := 1; depth <= fallbackDepth; depth++ {
+		col, _, err := engine.SearchContext(ctx, b, depth)
+		if err != nil {
+			break
+		}
+		fallback = col
+	}
+	scores, err := engine.NewSolver(sharedTable()).Analyze(ctx, b)
+
+	best := -1
+	bestScore := engine.MinScore - 1
+	fallbackScore, fallbackSolved := 0, false
+	for _, ms := range scores {
+		if ms.Score > bestScore {
+			best, bestScore = ms.Col, ms.Score
+		}
+		if ms.Col == fallback {
+			fallbackScore, fallbackSolved = ms.Score, true
+		}
+	}
+	if err == nil || bestScore > 0 {
+		return best
+	}
+	if fallbackSolved && fallbackScore < 0 && bestScore >= 0 {
+		return best
+	}
+	return fallback
+}
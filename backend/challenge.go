@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultChallengeTimeout = 30 * time.Second
+
+// Challenge is an invitation from one online user to another. The
+// challenger plays red if it is accepted.
+type Challenge struct {
+	ID    string
+	From  *Player
+	To    string
+	timer *time.Timer
+}
+
+// setOnline records that username is connected on conn.
+func (gs *GameServer) setOnline(username string, conn *Conn) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	gs.online[username] = conn
+}
+
+// setOffline forgets username unless it has since connected elsewhere, and
+// withdraws the challenges sent from conn, which can no longer play them.
+func (gs *GameServer) setOffline(username string, conn *Conn) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	if gs.online[username] == conn {
+		delete(gs.online, username)
+	}
+	for id, c := range gs.challenges {
+		if c.From.Username == username && c.From.Conn == conn {
+			c.timer.Stop()
+			delete(gs.challenges, id)
+			if to := gs.online[c.To]; to != nil {
+				to.WriteJSON(Message{Type: "challenge_expired", ChallengeID: id})
+			}
+			log.Printf("⚔ %s's challenge to %s withdrawn", username, c.To)
+		}
+	}
+}
+
+// challenge sends an invitation to an online user.
+func (gs *GameServer) challenge(from *Player, to string) (*Challenge, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	conn := gs.online[to]
+	switch {
+	case to == from.Username:
+		return nil, fmt.Errorf("you cannot challenge yourself")
+	case conn == nil:
+		return nil, fmt.Errorf("%s is not online", to)
+	case gs.inGame(to):
+		return nil, fmt.Errorf("%s is playing a game", to)
+	}
+
+	c := &Challenge{ID: generateID(), From: from, To: to}
+	c.timer = time.AfterFunc(gs.challengeTimeout, func() { gs.expireChallenge(c) })
+	gs.challenges[c.ID] = c
+
+	conn.WriteJSON(Message{Type: "challenge", ChallengeID: c.ID, Username: from.Username})
+	from.Conn.WriteJSON(Message{Type: "challenge_sent", ChallengeID: c.ID, Opponent: to})
+	log.Printf("⚔ %s challenged %s", from.Username, to)
+	return c, nil
+}
+
+// inGame reports whether username is playing an unfinished game that needs
+// them connected, which correspondence games do not. The caller holds
+// gs.mutex.
+func (gs *GameServer) inGame(username string) bool {
+	game := gs.playerGames[username]
+	if game == nil {
+		return false
+	}
+	game.mutex.RLock()
+	defer game.mutex.RUnlock()
+	return game.Winner == "" && !game.TimeControl.Correspondence
+}
+
+// takeChallenge removes a challenge addressed to username. The caller holds
+// gs.mutex.
+func (gs *GameServer) takeChallenge(id, username string) (*Challenge, error) {
+	c := gs.challenges[id]
+	if c == nil || c.To != username {
+		return nil, fmt.Errorf("challenge not found or expired")
+	}
+	c.timer.Stop()
+	delete(gs.challenges, id)
+	return c, nil
+}
+
+// acceptChallenge starts the game straight away, skipping the queue.
+func (gs *GameServer) acceptChallenge(player *Player, id string) (*GameState, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	c, err := gs.takeChallenge(id, player.Username)
+	if err != nil {
+		return nil, err
+	}
+	if gs.online[c.From.Username] != c.From.Conn {
+		return nil, fmt.Errorf("%s is not online", c.From.Username)
+	}
+	if gs.inGame(c.From.Username) {
+		return nil, fmt.Errorf("%s is playing a game", c.From.Username)
+	}
+	gs.leaveQueue(c.From.Username)
+	gs.leaveQueue(player.Username)
+	log.Printf("⚔ %s accepted %s's challenge", player.Username, c.From.Username)
+	return gs.createGame(c.From, player, nil, false), nil
+}
+
+func (gs *GameServer) declineChallenge(username, id string) error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	c, err := gs.takeChallenge(id, username)
+	if err != nil {
+		return err
+	}
+	c.From.Conn.WriteJSON(Message{Type: "challenge_declined", ChallengeID: id, Opponent: username})
+	log.Printf("⚔ %s declined %s's challenge", username, c.From.Username)
+	return nil
+}
+
+func (gs *GameServer) expireChallenge(c *Challenge) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	if gs.challenges[c.ID] != c {
+		return
+	}
+	delete(gs.challenges, c.ID)
+	msg := Message{Type: "challenge_expired", ChallengeID: c.ID}
+	c.From.Conn.WriteJSON(msg)
+	if conn := gs.online[c.To]; conn != nil {
+		conn.WriteJSON(msg)
+	}
+}
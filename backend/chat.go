@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxChatLength = 200 // characters in one line
+	chatBurst     = 5   // lines a connection may send within chatWindow
+	chatWindow    = 10 * time.Second
+)
+
+// Emote is a canned chat line. Emotes come from a fixed set, so they skip
+// the word filter.
+type Emote string
+
+const (
+	EmoteGG       Emote = "gg"
+	EmoteNiceMove Emote = "nice move"
+	EmoteGoodLuck Emote = "good luck"
+	EmoteThanks   Emote = "thanks"
+	EmoteOops     Emote = "oops"
+)
+
+var emotes = map[Emote]bool{EmoteGG: true, EmoteNiceMove: true, EmoteGoodLuck: true, EmoteThanks: true, EmoteOops: true}
+
+var (
+	errChatEmpty    = errors.New("chat message is empty")
+	errChatTooLong  = fmt.Errorf("chat messages are limited to %d characters", maxChatLength)
+	errChatTooFast  = errors.New("you are sending messages too quickly")
+	errUnknownEmote = errors.New("unknown emote")
+)
+
+// ChatLine is one chat message in a game. Message is the text as shown;
+// Original keeps what was typed when the word filter changed it. Only
+// moderators see Original, through the moderation endpoint.
+type ChatLine struct {
+	Username string    `json:"username"`
+	Message  string    `json:"message,omitempty"`
+	Original string    `json:"-"`
+	Emote    Emote     `json:"emote,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// moderatedLine is a chat line as moderators see it.
+type moderatedLine struct {
+	ChatLine
+	Original string `json:"original,omitempty"`
+}
+
+// rateLimiter allows burst events in any window, such as a connection's
+// chat lines.
+type rateLimiter struct {
+	burst  int
+	window time.Duration
+	sent   []time.Time
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	recent := l.sent[:0]
+	for _, t := range l.sent {
+		if now.Sub(t) < l.window {
+			recent = append(recent, t)
+		}
+	}
+	l.sent = recent
+	if len(l.sent) >= l.burst {
+		return false
+	}
+	l.sent = append(l.sent, now)
+	return true
+}
+
+// allowFrom rate-limits an HTTP request by client address, keeping one
+// limiter per address in limits, which gs.mutex guards, and dropping idle
+// ones.
+func (gs *GameServer) allowFrom(limits map[string]*rateLimiter, r *http.Request, burst int, window time.Duration, now time.Time) bool {
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	for a, l := range limits {
+		if len(l.sent) == 0 || now.Sub(l.sent[len(l.sent)-1]) >= window {
+			delete(limits, a)
+		}
+	}
+	limit := limits[addr]
+	if limit == nil {
+		limit = &rateLimiter{burst: burst, window: window}
+		limits[addr] = limit
+	}
+	return limit.allow(now)
+}
+
+// wordFilter is a set of blocked words, lower case.
+type wordFilter map[string]bool
+
+// newWordFilter parses a comma-separated word list, as in CHAT_FILTER.
+func newWordFilter(list string) wordFilter {
+	f := make(wordFilter)
+	for _, w := range strings.Split(list, ",") {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			f[w] = true
+		}
+	}
+	return f
+}
+
+// apply masks every blocked word in s with asterisks, ignoring case, and
+// reports whether it masked any.
+func (f wordFilter) apply(s string) (string, bool) {
+	var b strings.Builder
+	masked := false
+	word := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for s != "" {
+		end := strings.IndexFunc(s, func(r rune) bool { return !word(r) })
+		if end == 0 {
+			_, size := utf8.DecodeRuneInString(s)
+			b.WriteString(s[:size])
+			s = s[size:]
+			continue
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		if w := s[:end]; f[strings.ToLower(w)] {
+			b.WriteString(strings.Repeat("*", utf8.RuneCountInString(w)))
+			masked = true
+		} else {
+			b.WriteString(w)
+		}
+		s = s[end:]
+	}
+	return b.String(), masked
+}
+
+// chat sends a line from username to everyone in the game, players and
+// spectators, and stores it. Either text or an emote is sent.
+func (gs *GameServer) chat(game *GameState, username, text string, emote Emote) error {
+	line := ChatLine{Username: username, Emote: emote, Time: time.Now()}
+	if emote != "" {
+		if !emotes[emote] {
+			return errUnknownEmote
+		}
+	} else {
+		text = strings.TrimSpace(text)
+		switch {
+		case text == "":
+			return errChatEmpty
+		case utf8.RuneCountInString(text) > maxChatLength:
+			return errChatTooLong
+		}
+		var masked bool
+		if line.Message, masked = gs.chatFilter.apply(text); masked {
+			line.Original = text
+		}
+	}
+
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if seatOf(game, username) == nil {
+		return errNotYourGame
+	}
+	game.Chat = append(game.Chat, line)
+	gs.saveChat(game.ID, line)
+
+	msg := Message{Type: "chat", Username: username, Message: line.Message, Emote: line.Emote}
+	for _, p := range []*Player{game.Player1, game.Player2} {
+		if p != nil && p.Conn != nil {
+			p.Conn.WriteJSON(msg)
+		}
+	}
+	gs.sendSpectators(game, msg)
+	return nil
+}
+
+// saveChat stores a chat line. Lines are written as they are sent, before
+// the game itself is saved.
+func (gs *GameServer) saveChat(gameID string, line ChatLine) {
+	if gs.db == nil {
+		return
+	}
+	_, err := gs.db.Exec(`
+		INSERT INTO chat_messages (game_id, username, message, original, emote, sent_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+	`, gameID, line.Username, line.Message, line.Original, string(line.Emote), line.Time)
+	if err != nil {
+		log.Println("❌ Error saving chat:", err)
+	}
+}
+
+// getChat serves GET /games/{id}/chat, the game's chat in order as the
+// players saw it.
+func (gs *GameServer) getChat(w http.ResponseWriter, gameID string) {
+	if lines, ok := gs.chatLines(w, gameID); ok {
+		json.NewEncoder(w).Encode(lines)
+	}
+}
+
+// handleModeration serves GET /moderation/games/{id}/chat, the game's chat
+// with what was typed before the word filter. It needs MODERATOR_TOKEN as a
+// bearer token, and is off when no token is configured.
+func (gs *GameServer) handleModeration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/moderation/games/"), "/"), "/")
+	if r.Method != http.MethodGet || gs.moderatorToken == "" || len(parts) != 2 || parts[0] == "" || parts[1] != "chat" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+		return
+	}
+	auth := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(auth, []byte("Bearer "+gs.moderatorToken)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "moderator token required"})
+		return
+	}
+
+	lines, ok := gs.chatLines(w, parts[0])
+	if !ok {
+		return
+	}
+	res := make([]moderatedLine, len(lines))
+	for i, line := range lines {
+		res[i] = moderatedLine{ChatLine: line, Original: line.Original}
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// chatLines loads a game's chat, or writes the error response and returns
+// false.
+func (gs *GameServer) chatLines(w http.ResponseWriter, gameID string) ([]ChatLine, bool) {
+	gs.mutex.RLock()
+	game := gs.games[gameID]
+	gs.mutex.RUnlock()
+
+	lines := []ChatLine{}
+	var err error
+	switch {
+	case gs.db != nil:
+		lines, err = gs.loadChat(gameID)
+		if err == nil && len(lines) == 0 && game == nil {
+			_, err = gs.loadGame(gameID)
+		}
+	case game != nil:
+		game.mutex.RLock()
+		lines = append(lines, game.Chat...)
+		game.mutex.RUnlock()
+	default:
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "game not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Println("❌ Error loading chat:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "could not load chat"})
+		return nil, false
+	}
+	return lines, true
+}
+
+func (gs *GameServer) loadChat(gameID string) ([]ChatLine, error) {
+	rows, err := gs.db.Query(`
+		SELECT username, message, original, emote, sent_at FROM chat_messages
+		WHERE game_id = $1 ORDER BY id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []ChatLine{}
+	for rows.Next() {
+		var line ChatLine
+		var message, original, emote sql.NullString
+		if err := rows.Scan(&line.Username, &message, &original, &emote, &line.Time); err != nil {
+			return nil, err
+		}
+		line.Message, line.Original, line.Emote = message.String, original.String, Emote(emote.String)
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How a game ended, stored with it and sent in game_over.
+const (
+	EndNormal  = "normal"  // four in a row or a full board
+	EndForfeit = "forfeit" // a player stayed disconnected
+	EndTimeout = "timeout" // a player ran out of time
+
+	EndResigned   = "resigned"    // a player resigned
+	EndAgreedDraw = "agreed_draw" // the players agreed a draw
+)
+
+// maxCorrespondenceHours caps the time for one correspondence move at two
+// weeks.
+const maxCorrespondenceHours = 14 * 24
+
+var errTimeUp = errors.New("time is up")
+
+// TimeControl is a game's clock setting, written as in the notation's
+// TimeControl tag: "180+2" is 180 seconds for the game plus 2 seconds after
+// every move, and "30/move" is 30 seconds for each move. "24h/move" is a
+// correspondence game with 24 hours for each move. The zero value is an
+// untimed game, written "-".
+type TimeControl struct {
+	Base           time.Duration
+	Increment      time.Duration
+	PerMove        bool
+	Correspondence bool // players need not stay connected
+}
+
+func parseTimeControl(s string) (TimeControl, error) {
+	var tc TimeControl
+	bad := fmt.Errorf("unknown time control %q", s)
+	switch {
+	case s == "" || s == "-":
+		return tc, nil
+	case strings.HasSuffix(s, "h/move"):
+		hours, err := strconv.Atoi(strings.TrimSuffix(s, "h/move"))
+		if err != nil || hours <= 0 || hours > maxCorrespondenceHours {
+			return tc, bad
+		}
+		return TimeControl{Base: time.Duration(hours) * time.Hour, PerMove: true, Correspondence: true}, nil
+	case strings.HasSuffix(s, "/move"):
+		secs, err := strconv.Atoi(strings.TrimSuffix(s, "/move"))
+		if err != nil || secs <= 0 {
+			return tc, bad
+		}
+		return TimeControl{Base: time.Duration(secs) * time.Second, PerMove: true}, nil
+	}
+	base, inc, _ := strings.Cut(s, "+")
+	secs, err := strconv.Atoi(base)
+	if err != nil || secs <= 0 {
+		return tc, bad
+	}
+	tc.Base = time.Duration(secs) * time.Second
+	if inc != "" {
+		secs, err := strconv.Atoi(inc)
+		if err != nil || secs < 0 {
+			return tc, bad
+		}
+		tc.Increment = time.Duration(secs) * time.Second
+	}
+	return tc, nil
+}
+
+func (tc TimeControl) String() string {
+	switch {
+	case tc.Base == 0:
+		return "-"
+	case tc.Correspondence:
+		return fmt.Sprintf("%dh/move", int(tc.Base.Hours()))
+	case tc.PerMove:
+		return fmt.Sprintf("%d/move", int(tc.Base.Seconds()))
+	}
+	return fmt.Sprintf("%d+%d", int(tc.Base.Seconds()), int(tc.Increment.Seconds()))
+}
+
+// clockRuns reports whether color plays against a clock. The bot has none.
+func clockRuns(game *GameState, color Color) bool {
+	return game.TimeControl.Base > 0 && !(game.IsBot && color == Yellow)
+}
+
+// startClock sets both clocks and starts red's. The caller holds
+// game.mutex.
+func (gs *GameServer) startClock(game *GameState) {
+	if game.TimeControl.Base == 0 {
+		return
+	}
+	game.Clock = make(map[Color]time.Duration)
+	for _, color := range []Color{Red, Yellow} {
+		if clockRuns(game, color) {
+			game.Clock[color] = game.TimeControl.Base
+		}
+	}
+	gs.startTurnClock(game, Red)
+}
+
+// startTurnClock starts color's clock and arms the flag for when it runs
+// out. The caller holds game.mutex.
+func (gs *GameServer) startTurnClock(game *GameState, color Color) {
+	gs.stopClock(game)
+	game.turnStart = time.Now()
+	if !clockRuns(game, color) {
+		return
+	}
+	ply := len(game.Moves)
+	game.flagTimer = time.AfterFunc(game.Clock[color], func() { gs.flagFall(game, color, ply) })
+}
+
+// stopClock disarms the flag. The caller holds game.mutex.
+func (gs *GameServer) stopClock(game *GameState) {
+	if game.flagTimer != nil {
+		game.flagTimer.Stop()
+		game.flagTimer = nil
+	}
+}
+
+// pressClock charges color for the move it just made and adds the
+// increment. If color had already run out the game is lost on time and
+// pressClock returns false. The caller holds game.mutex.
+func (gs *GameServer) pressClock(game *GameState, color Color) bool {
+	if !clockRuns(game, color) {
+		return true
+	}
+	left := game.Clock[color] - time.Since(game.turnStart)
+	if left <= 0 {
+		gs.timeout(game, color)
+		return false
+	}
+	if game.TimeControl.PerMove {
+		left = game.TimeControl.Base
+	}
+	game.Clock[color] = left + game.TimeControl.Increment
+	return true
+}
+
+// flagFall ends the game if color is still thinking about the same move
+// when its time runs out.
+func (gs *GameServer) flagFall(game *GameState, color Color, ply int) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.Winner != "" || game.CurrentPlayer != color || len(game.Moves) != ply {
+		return
+	}
+	gs.timeout(game, color)
+}
+
+// timeout ends the game as a loss for color. The caller holds game.mutex.
+func (gs *GameServer) timeout(game *GameState, color Color) {
+	game.Clock[color] = 0
+	log.Printf("⏰ %s ran out of time", color)
+	winner := Red
+	if color == Red {
+		winner = Yellow
+	}
+	gs.endGame(game, string(winner), EndTimeout, nil)
+}
+
+// clocks returns the time left on each running clock in milliseconds, as of
+// now. The caller holds game.mutex.
+func clocks(game *GameState) map[Color]int64 {
+	if game.Clock == nil {
+		return nil
+	}
+	ms := make(map[Color]int64)
+	for color, left := range game.Clock {
+		if color == game.CurrentPlayer && game.Winner == "" {
+			left -= time.Since(game.turnStart)
+		}
+		if left < 0 {
+			left = 0
+		}
+		ms[color] = left.Milliseconds()
+	}
+	return ms
+}
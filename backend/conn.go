@@ -0,0 +1,28 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is a client's WebSocket connection. A connection is written from its
+// own handler and from whichever goroutines broadcast to the games it plays
+// or watches, but gorilla/websocket allows only one writer at a time, so
+// every write goes through WriteJSON, which takes turns. Reads stay with the
+// handler.
+type Conn struct {
+	*websocket.Conn
+	writeMutex sync.Mutex
+}
+
+func newConn(ws *websocket.Conn) *Conn {
+	return &Conn{Conn: ws}
+}
+
+// WriteJSON sends v as one JSON message, waiting for any write in progress.
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.Conn.WriteJSON(v)
+}
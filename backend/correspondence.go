@@ -0,0 +1,297 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// deadlineSweepInterval is how often the database is checked for
+// correspondence games whose deadline passed while nobody had them loaded.
+const deadlineSweepInterval = time.Minute
+
+var (
+	errNotCorrespondence = errors.New("not a correspondence game")
+	errNotYourGame       = errors.New("you are not playing this game")
+	errFinished          = errors.New("game is over")
+)
+
+// CorrespondenceGame is an unfinished correspondence game from one player's
+// side. Deadline is when the side to move runs out of time.
+type CorrespondenceGame struct {
+	ID          string     `json:"id"`
+	Opponent    string     `json:"opponent"`
+	Color       Color      `json:"color"`
+	Moves       int        `json:"moves"`
+	YourTurn    bool       `json:"your_turn"`
+	TimeControl string     `json:"time_control"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+}
+
+// deadline returns when the side to move runs out of time in a
+// correspondence game in progress, or nil. The caller holds game.mutex.
+func deadline(game *GameState) *time.Time {
+	if !game.TimeControl.Correspondence || game.Winner != "" || !clockRuns(game, game.CurrentPlayer) {
+		return nil
+	}
+	d := game.turnStart.Add(game.Clock[game.CurrentPlayer])
+	return &d
+}
+
+// resumeGame attaches conn to username's seat in a correspondence game,
+// loading the game from the database if the server does not hold it, and
+// sends the position.
+func (gs *GameServer) resumeGame(conn *Conn, username, gameID string) (*GameState, *Player, error) {
+	game, err := gs.correspondenceGame(gameID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.Winner != "" {
+		return nil, nil, errFinished
+	}
+	seat, opponent := game.Player1, game.Player2
+	if seat.Username != username {
+		seat, opponent = opponent, seat
+	}
+	if seat.Username != username {
+		return nil, nil, errNotYourGame
+	}
+	seat.Conn, seat.Disconnected = conn, false
+	gs.playerGames[username] = game
+	conn.WriteJSON(Message{
+		Type: "resumed", GameID: game.ID, Color: seat.Color, Opponent: opponent.Username,
+		Board: game.Board, CurrentPlayer: game.CurrentPlayer, TimeControl: game.TimeControl.String(), Clock: clocks(game),
+	})
+	log.Printf("📬 %s resumed %s", username, game.ID)
+	return game, seat, nil
+}
+
+// correspondenceGame returns a correspondence game in progress, restoring it
+// from the database if it is not in memory.
+func (gs *GameServer) correspondenceGame(gameID string) (*GameState, error) {
+	gs.mutex.RLock()
+	game := gs.games[gameID]
+	gs.mutex.RUnlock()
+	if game == nil {
+		if gs.db == nil {
+			return nil, errGameNotFound
+		}
+		restored, err := gs.restoreGame(gameID)
+		if err == sql.ErrNoRows {
+			return nil, errGameNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		gs.mutex.Lock()
+		if game = gs.games[gameID]; game == nil {
+			game = restored
+			gs.games[gameID] = game
+			game.mutex.Lock()
+			gs.startTurnClock(game, game.CurrentPlayer)
+			if game.IsBot && game.CurrentPlayer == Yellow {
+				gs.startBotTurn(game)
+			}
+			game.mutex.Unlock()
+			log.Printf("📬 Restored %s (%d moves)", game.ID, len(game.Moves))
+		}
+		gs.mutex.Unlock()
+	}
+	if !game.TimeControl.Correspondence {
+		return nil, errNotCorrespondence
+	}
+	return game, nil
+}
+
+// restoreGame rebuilds an unfinished correspondence game from the database,
+// with both players disconnected and the clock not yet running.
+func (gs *GameServer) restoreGame(gameID string) (*GameState, error) {
+	rec, err := gs.loadGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	var rated sql.NullBool
+	var due sql.NullTime
+	err = gs.db.QueryRow(`SELECT rated, deadline FROM games WHERE id = $1`, gameID).Scan(&rated, &due)
+	if err != nil {
+		return nil, err
+	}
+	if rec.EndTime != nil {
+		return nil, errFinished
+	}
+	tc, err := parseTimeControl(rec.TimeControl)
+	if err != nil || !tc.Correspondence {
+		return nil, errNotCorrespondence
+	}
+
+	board := make([][]Color, ROWS)
+	for i := range board {
+		board[i] = make([]Color, COLS)
+	}
+	for _, m := range rec.Moves {
+		board[m.Row][m.Column] = m.Color
+	}
+	game := &GameState{
+		ID: rec.ID, Board: board, CurrentPlayer: Red, StartTime: rec.StartTime,
+		Player1: &Player{Username: rec.Player1, Color: Red, Disconnected: true},
+		Player2: &Player{Username: rec.Player2, Color: Yellow, Disconnected: true},
+		IsBot:   rec.IsBot, Difficulty: rec.Difficulty, Rated: rated.Bool,
+		TimeControl: tc, Moves: rec.Moves,
+	}
+	if len(rec.Moves)%2 == 1 {
+		game.CurrentPlayer = Yellow
+	}
+	game.Clock = make(map[Color]time.Duration)
+	for _, color := range []Color{Red, Yellow} {
+		if clockRuns(game, color) {
+			game.Clock[color] = tc.Base
+		}
+	}
+	if due.Valid && clockRuns(game, game.CurrentPlayer) {
+		game.Clock[game.CurrentPlayer] = time.Until(due.Time)
+	}
+	return game, nil
+}
+
+// runDeadlines restores correspondence games whose deadline has passed so
+// their clocks can end them, even if neither player comes back.
+func (gs *GameServer) runDeadlines(interval time.Duration) {
+	if gs.db == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		rows, err := gs.db.Query(`SELECT id FROM games WHERE end_time IS NULL AND deadline < $1`, time.Now())
+		if err != nil {
+			log.Println("❌ Error checking deadlines:", err)
+			continue
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if rows.Scan(&id) == nil {
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+		for _, id := range ids {
+			if _, err := gs.correspondenceGame(id); err != nil {
+				log.Printf("❌ Error restoring %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// getPlayerGames serves GET /players/{username}/games, the player's
+// unfinished correspondence games, most urgent first. ?status=your_turn or
+// ?status=their_turn filters by whose move it is.
+func (gs *GameServer) getPlayerGames(w http.ResponseWriter, r *http.Request, username string) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "your_turn" && status != "their_turn" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "status must be your_turn or their_turn"})
+		return
+	}
+
+	var games []CorrespondenceGame
+	var err error
+	if gs.db != nil {
+		games, err = gs.storedCorrespondenceGames(username)
+	} else {
+		games = gs.heldCorrespondenceGames(username)
+	}
+	if err != nil {
+		log.Println("❌ Error loading games:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "could not load games"})
+		return
+	}
+
+	res := []CorrespondenceGame{}
+	for _, g := range games {
+		if status == "" || g.YourTurn == (status == "your_turn") {
+			res = append(res, g)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i].Deadline, res[j].Deadline
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return res[i].ID < res[j].ID
+	})
+	json.NewEncoder(w).Encode(res)
+}
+
+// correspondenceEntry describes a game from username's side.
+func correspondenceEntry(username, id, player1, player2 string, moves int, tc string, due *time.Time) CorrespondenceGame {
+	g := CorrespondenceGame{ID: id, Color: Red, Opponent: player2, Moves: moves, TimeControl: tc, Deadline: due}
+	if player1 != username {
+		g.Color, g.Opponent = Yellow, player1
+	}
+	g.YourTurn = (moves%2 == 0) == (g.Color == Red)
+	return g
+}
+
+func (gs *GameServer) storedCorrespondenceGames(username string) ([]CorrespondenceGame, error) {
+	rows, err := gs.db.Query(`
+		SELECT g.id, g.player1, g.player2, g.time_control, g.deadline, COUNT(m.ply)
+		FROM games g
+		LEFT JOIN moves m ON m.game_id = g.id
+		WHERE g.end_time IS NULL AND (g.player1 = $1 OR g.player2 = $1)
+		GROUP BY g.id, g.player1, g.player2, g.time_control, g.deadline
+	`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []CorrespondenceGame
+	for rows.Next() {
+		var id, player1, player2 string
+		var tc sql.NullString
+		var due sql.NullTime
+		var moves int
+		if err := rows.Scan(&id, &player1, &player2, &tc, &due, &moves); err != nil {
+			return nil, err
+		}
+		g := correspondenceEntry(username, id, player1, player2, moves, tc.String, nil)
+		if due.Valid {
+			g.Deadline = &due.Time
+		}
+		games = append(games, g)
+	}
+	return games, rows.Err()
+}
+
+// heldCorrespondenceGames lists the games in memory, for running without a
+// database.
+func (gs *GameServer) heldCorrespondenceGames(username string) []CorrespondenceGame {
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	var games []CorrespondenceGame
+	for _, game := range gs.games {
+		game.mutex.RLock()
+		if game.TimeControl.Correspondence && game.Winner == "" &&
+			(game.Player1.Username == username || game.Player2.Username == username) {
+			games = append(games, correspondenceEntry(username, game.ID, game.Player1.Username, game.Player2.Username,
+				len(game.Moves), game.TimeControl.String(), deadline(game)))
+		}
+		game.mutex.RUnlock()
+	}
+	return games
+}
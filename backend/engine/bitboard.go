@@ -0,0 +1,177 @@
+package engine
+
+// Bitboard is a compact position for search and fast win checks: one mask
+// per side plus the column heights. Column c owns bits c*(Rows+1) up to
+// c*(Rows+1)+Rows-1, bottom first; the spare bit on top of each column keeps
+// shifted lines from wrapping into the next column. A Bitboard is a plain
+// value, so copying it is the cheapest way to branch a search.
+type Bitboard struct {
+	discs  [2]uint64
+	height [Cols]uint8
+	toMove Disc
+	moves  int
+}
+
+const colBits = Rows + 1
+
+var bottomMask = func() uint64 {
+	var m uint64
+	for c := 0; c < Cols; c++ {
+		m |= 1 << (c * colBits)
+	}
+	return m
+}()
+
+// NewBitboard returns the empty board with red to move.
+func NewBitboard() Bitboard {
+	return Bitboard{toMove: Red}
+}
+
+// BitboardFromGrid packs a grid into masks. Heights are only meaningful when
+// the grid obeys gravity; win checks work on any grid.
+func BitboardFromGrid(g Grid, toMove Disc) Bitboard {
+	b := Bitboard{toMove: toMove}
+	for c := 0; c < Cols; c++ {
+		for r := Rows - 1; r >= 0; r-- {
+			d := g[r][c]
+			if d == Empty {
+				continue
+			}
+			b.discs[d-Red] |= cellBit(r, c)
+			b.moves++
+			if h := uint8(Rows - r); h > b.height[c] {
+				b.height[c] = h
+			}
+		}
+	}
+	return b
+}
+
+// Bitboard returns a copy of the position's Bitboard.
+func (p *Position) Bitboard() Bitboard {
+	return p.bits
+}
+
+func cellBit(row, col int) uint64 {
+	return 1 << (col*colBits + Rows - 1 - row)
+}
+
+// ToMove returns the side whose turn it is.
+func (b *Bitboard) ToMove() Disc {
+	return b.toMove
+}
+
+// Moves returns the number of discs on the board.
+func (b *Bitboard) Moves() int {
+	return b.moves
+}
+
+// Height returns the number of discs in a column.
+func (b *Bitboard) Height(col int) int {
+	return int(b.height[col])
+}
+
+// Mask returns the occupied cells.
+func (b *Bitboard) Mask() uint64 {
+	return b.discs[0] | b.discs[1]
+}
+
+// Discs returns the cells occupied by side.
+func (b *Bitboard) Discs(side Disc) uint64 {
+	return b.discs[side-Red]
+}
+
+// Key identifies the position uniquely: the side to move's discs plus the
+// occupied mask, which together also encode every column height.
+func (b *Bitboard) Key() uint64 {
+	return b.discs[b.toMove-Red] + b.Mask() + bottomMask
+}
+
+// CanPlay reports whether col has room for another disc. It does not check
+// whether the game is already won.
+func (b *Bitboard) CanPlay(col int) bool {
+	return col >= 0 && col < Cols && b.height[col] < Rows
+}
+
+// IsFull reports whether every column is full.
+func (b *Bitboard) IsFull() bool {
+	return b.moves == Rows*Cols
+}
+
+// Play drops a disc for the side to move. The caller must check CanPlay.
+func (b *Bitboard) Play(col int) {
+	b.discs[b.toMove-Red] |= 1 << (col*colBits + int(b.height[col]))
+	b.height[col]++
+	b.moves++
+	b.toMove = b.toMove.Opponent()
+}
+
+// Undo removes the top disc of col, which must be the last one played.
+func (b *Bitboard) Undo(col int) {
+	b.toMove = b.toMove.Opponent()
+	b.moves--
+	b.height[col]--
+	b.discs[b.toMove-Red] &^= 1 << (col*colBits + int(b.height[col]))
+}
+
+// IsWinningMove reports whether the side to move connects four in col.
+func (b *Bitboard) IsWinningMove(col int) bool {
+	return b.WinsWith(b.toMove, col)
+}
+
+// WinsWith reports whether side would connect four by dropping in col,
+// regardless of whose turn it is.
+func (b *Bitboard) WinsWith(side Disc, col int) bool {
+	if !b.CanPlay(col) {
+		return false
+	}
+	m := b.discs[side-Red] | 1<<(col*colBits+int(b.height[col]))
+	return hasFour(m)
+}
+
+// Winner returns the side that has connected four, or Empty.
+func (b *Bitboard) Winner() Disc {
+	if hasFour(b.discs[0]) {
+		return Red
+	}
+	if hasFour(b.discs[1]) {
+		return Yellow
+	}
+	return Empty
+}
+
+// InFour reports whether the disc at the given cell is part of four in a row.
+func (b *Bitboard) InFour(row, col int) bool {
+	bit := cellBit(row, col)
+	for _, m := range b.discs {
+		if m&bit != 0 {
+			return fourCells(m)&bit != 0
+		}
+	}
+	return false
+}
+
+// shifts are the bit distances between neighbours in each direction:
+// vertical, horizontal and the two diagonals.
+var shifts = [4]uint{1, colBits, colBits - 1, colBits + 1}
+
+func hasFour(m uint64) bool {
+	for _, s := range shifts {
+		x := m & (m >> s)
+		if x&(x>>(2*s)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fourCells returns every cell of m that belongs to a line of four or more.
+func fourCells(m uint64) uint64 {
+	var cells uint64
+	for _, s := range shifts {
+		x := m & (m >> s)
+		start := x & (x >> (2 * s))
+		cells |= start | start<<s | start<<(2*s) | start<<(3*s)
+	}
+	return cells
+}
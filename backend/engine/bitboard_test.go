@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// The bitboard must agree with the slice-based Position and with Grid's line
+// walk on every position reached in random games.
+func TestBitboardMatchesPosition(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for game := 0; game < 2000; game++ {
+		p := NewPosition()
+		b := NewBitboard()
+		for !p.IsOver() {
+			for col := 0; col < Cols; col++ {
+				if b.CanPlay(col) != p.CanPlay(col) {
+					t.Fatalf("moves %v: CanPlay(%d) differs", p.Moves(), col)
+				}
+				if !p.CanPlay(col) {
+					continue
+				}
+				trial := p.Clone()
+				row, _ := trial.Play(col)
+				g := trial.Cells()
+				if b.IsWinningMove(col) != (g.LineThrough(row, col) != nil) {
+					t.Fatalf("moves %v: IsWinningMove(%d) differs", p.Moves(), col)
+				}
+			}
+
+			moves := p.LegalMoves()
+			col := moves[rng.Intn(len(moves))]
+			row, _ := p.Play(col)
+			b.Play(col)
+
+			g := p.Cells()
+			if (b.Winner() != Empty) != (g.LineThrough(row, col) != nil) || b.Winner() != p.Winner() {
+				t.Fatalf("moves %v: winner %v, want %v", p.Moves(), b.Winner(), p.Winner())
+			}
+			if b.IsFull() != p.IsFull() || b.ToMove() != p.ToMove() {
+				t.Fatalf("moves %v: bitboard out of sync", p.Moves())
+			}
+			if b != p.Bitboard() || b != BitboardFromGrid(g, p.ToMove()) {
+				t.Fatalf("moves %v: incremental and packed bitboards differ", p.Moves())
+			}
+		}
+	}
+}
+
+func TestBitboardInFourMatchesGrid(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 5000; i++ {
+		var g Grid
+		for r := 0; r < Rows; r++ {
+			for c := 0; c < Cols; c++ {
+				g[r][c] = Disc(rng.Intn(3))
+			}
+		}
+		b := BitboardFromGrid(g, Red)
+		for r := 0; r < Rows; r++ {
+			for c := 0; c < Cols; c++ {
+				if b.InFour(r, c) != (g.LineThrough(r, c) != nil) {
+					t.Fatalf("grid %v: InFour(%d, %d) differs", g, r, c)
+				}
+			}
+		}
+	}
+}
+
+func TestBitboardInFour(t *testing.T) {
+	for name, cells := range map[string][]Cell{
+		"horizontal": {{5, 0}, {5, 1}, {5, 2}, {5, 3}},
+		"vertical":   {{2, 0}, {3, 0}, {4, 0}, {5, 0}},
+		"diagonal":   {{2, 0}, {3, 1}, {4, 2}, {5, 3}},
+	} {
+		var g Grid
+		for _, c := range cells {
+			g[c.Row][c.Col] = Red
+		}
+		b := BitboardFromGrid(g, Yellow)
+		if last := cells[len(cells)-1]; !b.InFour(last.Row, last.Col) {
+			t.Errorf("%s four not detected", name)
+		}
+		if b.InFour(0, 6) {
+			t.Errorf("%s: empty cell should not be in four", name)
+		}
+	}
+}
+
+func TestGridIsFull(t *testing.T) {
+	var g Grid
+	if g.IsFull() {
+		t.Error("empty grid should not be full")
+	}
+	for r := range g {
+		for c := range g[r] {
+			g[r][c] = Red
+		}
+	}
+	if !g.IsFull() {
+		t.Error("full grid not detected")
+	}
+}
+
+func TestBitboardUndo(t *testing.T) {
+	b := NewBitboard()
+	start := b
+	cols := []int{3, 3, 2, 4, 6, 0}
+	for _, c := range cols {
+		b.Play(c)
+	}
+	for i := len(cols) - 1; i >= 0; i-- {
+		b.Undo(cols[i])
+	}
+	if b != start {
+		t.Error("undoing every move should restore the empty board")
+	}
+}
+
+func TestBitboardKeyIsUnique(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	seen := make(map[uint64]Grid)
+	for game := 0; game < 500; game++ {
+		p := NewPosition()
+		for !p.IsOver() {
+			moves := p.LegalMoves()
+			p.Play(moves[rng.Intn(len(moves))])
+			b := p.Bitboard()
+			if g, ok := seen[b.Key()]; ok && g != p.Cells() {
+				t.Fatalf("key collision between %v and %v", g, p.Cells())
+			}
+			seen[b.Key()] = p.Cells()
+		}
+	}
+}
+
+func BenchmarkBitboardWinningMove(b *testing.B) {
+	bb := NewBitboard()
+	for _, c := range []int{3, 3, 2, 4, 1} {
+		bb.Play(c)
+	}
+	for i := 0; i < b.N; i++ {
+		for col := 0; col < Cols; col++ {
+			bb.IsWinningMove(col)
+		}
+	}
+}
+
+func BenchmarkPositionWinningMove(b *testing.B) {
+	p := NewPosition()
+	for _, c := range []int{3, 3, 2, 4, 1} {
+		p.Play(c)
+	}
+	for i := 0; i < b.N; i++ {
+		for col := 0; col < Cols; col++ {
+			p.Play(col)
+			p.Undo()
+		}
+	}
+}
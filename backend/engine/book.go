@@ -0,0 +1,40 @@
+package engine
+
+// bookLines maps opening move sequences, written as 1-based column digits,
+// to the best reply (also 1-based). Early positions are far too expensive to
+// solve within a move's time budget, so the bot's first two replies as
+// yellow were solved offline with Solver.Analyze, taking the centre-most of
+// equally scored moves; red's first move is the known winning centre
+// opening. Mirror images are added automatically, so only lines starting in
+// columns 1-4 are listed.
+var bookLines = map[string]int{
+	"": 4,
+
+	"4": 4, "441": 4, "442": 3, "443": 5, "444": 4,
+	"3": 4, "341": 4, "342": 4, "343": 3, "344": 4, "345": 4, "346": 4, "347": 4,
+	"2": 3, "231": 3, "232": 2, "233": 3, "234": 4, "235": 3, "236": 3, "237": 3,
+	"1": 4, "141": 4, "142": 4, "143": 4, "144": 4, "145": 4, "146": 4, "147": 3,
+}
+
+var openingBook = func() map[uint64]int {
+	book := make(map[uint64]int)
+	for line, reply := range bookLines {
+		b, mirror := NewBitboard(), NewBitboard()
+		for _, ch := range line {
+			col := int(ch - '1')
+			b.Play(col)
+			mirror.Play(Cols - 1 - col)
+		}
+		book[b.Key()] = reply - 1
+		if mirror.Key() != b.Key() {
+			book[mirror.Key()] = Cols - reply
+		}
+	}
+	return book
+}()
+
+// BookMove returns the opening book's move for the position, if it has one.
+func BookMove(b Bitboard) (int, bool) {
+	col, ok := openingBook[b.Key()]
+	return col, ok
+}
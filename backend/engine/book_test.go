@@ -0,0 +1,35 @@
+package engine
+
+import "testing"
+
+func TestBookCoversMirrorsAndTranspositions(t *testing.T) {
+	for line, reply := range bookLines {
+		b, mirror := NewBitboard(), NewBitboard()
+		for _, ch := range line {
+			col := int(ch - '1')
+			b.Play(col)
+			mirror.Play(Cols - 1 - col)
+		}
+		if col, ok := BookMove(b); !ok || col != reply-1 || !b.CanPlay(col) {
+			t.Errorf("line %q: book gives %d, %v, want %d", line, col, ok, reply-1)
+		}
+		if col, ok := BookMove(mirror); mirror.Key() != b.Key() && (!ok || col != Cols-reply) {
+			t.Errorf("mirror of %q: book gives %d, %v, want %d", line, col, ok, Cols-reply)
+		}
+	}
+
+	// Red's c3 and a1 in either order reach the same position
+	a, b := NewBitboard(), NewBitboard()
+	for _, col := range []int{0, 3, 2} {
+		a.Play(col)
+	}
+	for _, col := range []int{2, 3, 0} {
+		b.Play(col)
+	}
+	if x, _ := BookMove(a); x != 3 {
+		t.Errorf("expected the centre after 143, got %d", x)
+	}
+	if y, _ := BookMove(b); y != 3 {
+		t.Errorf("expected the centre after 341, got %d", y)
+	}
+}
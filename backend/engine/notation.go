@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Games are written as the columns played, numbered 1 to 7 from the left,
+// e.g. "4453". A record adds a header of tags in front of the moves:
+//
+//	[Red "alice"]
+//	[Yellow "bob"]
+//	[Result "1-0"]
+//
+//	4453...
+//
+// Whitespace between moves is ignored.
+
+// Results as written in the Result tag.
+const (
+	ResultRed     = "1-0"
+	ResultYellow  = "0-1"
+	ResultDraw    = "1/2-1/2"
+	ResultUnknown = "*"
+)
+
+var (
+	ErrBadTag         = errors.New("malformed tag")
+	ErrResultMismatch = errors.New("result does not match the final position")
+)
+
+// tagOrder lists the standard tags in the order they are written. Other tags
+// follow in alphabetical order.
+var tagOrder = []string{"Red", "Yellow", "Date", "Result", "TimeControl", "Bot"}
+
+// PlyError reports an illegal move, counting plies from 1.
+type PlyError struct {
+	Ply int
+	Err error
+}
+
+func (e *PlyError) Error() string {
+	return fmt.Sprintf("ply %d: %v", e.Ply, e.Err)
+}
+
+func (e *PlyError) Unwrap() error {
+	return e.Err
+}
+
+// FormatMoves writes columns as 1-based digits.
+func FormatMoves(moves []int) string {
+	var sb strings.Builder
+	for _, col := range moves {
+		sb.WriteByte(byte('1' + col))
+	}
+	return sb.String()
+}
+
+// ParseMoves replays a move string from the empty board. An unreadable or
+// illegal move is reported as a *PlyError.
+func ParseMoves(s string) (*Position, error) {
+	var moves []int
+	for _, ch := range s {
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			continue
+		case ch < '1' || ch > '0'+Cols:
+			return nil, &PlyError{Ply: len(moves) + 1, Err: ErrInvalidColumn}
+		}
+		moves = append(moves, int(ch-'1'))
+	}
+	return Replay(moves)
+}
+
+// Replay plays 0-based columns from the empty board. An illegal move is
+// reported as a *PlyError.
+func Replay(moves []int) (*Position, error) {
+	pos := NewPosition()
+	for i, col := range moves {
+		if _, err := pos.Play(col); err != nil {
+			return nil, &PlyError{Ply: i + 1, Err: err}
+		}
+	}
+	return pos, nil
+}
+
+// Record is a game with its header tags, such as the players and result.
+type Record struct {
+	Tags  map[string]string
+	Moves []int
+}
+
+// String writes the record: its tags, a blank line, then the moves.
+func (r *Record) String() string {
+	var sb strings.Builder
+	for _, name := range r.tagNames() {
+		fmt.Fprintf(&sb, "[%s %s]\n", name, strconv.Quote(r.Tags[name]))
+	}
+	if sb.Len() > 0 {
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(FormatMoves(r.Moves))
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func (r *Record) tagNames() []string {
+	var names, rest []string
+	seen := make(map[string]bool)
+	for _, name := range tagOrder {
+		if _, ok := r.Tags[name]; ok {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	for name := range r.Tags {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// ParseRecord reads a record, or a bare move string, and returns it with its
+// final position. Every move must be legal. A Result tag must agree with the
+// board when the moves end the game; games that stop early, by resignation
+// or on time, may carry any result.
+func ParseRecord(text string) (*Record, *Position, error) {
+	r := &Record{Tags: make(map[string]string)}
+	lines := strings.Split(text, "\n")
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "[") {
+			break
+		}
+		name, value, err := parseTag(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		r.Tags[name] = value
+	}
+
+	pos, err := ParseMoves(strings.Join(lines[i:], "\n"))
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Moves = pos.Moves()
+	if result, ok := r.Tags["Result"]; ok {
+		switch result {
+		case ResultRed, ResultYellow, ResultDraw, ResultUnknown:
+		default:
+			return nil, nil, fmt.Errorf("result %q: %w", result, ErrBadTag)
+		}
+		if pos.IsOver() && result != pos.Result() {
+			return nil, nil, ErrResultMismatch
+		}
+	}
+	return r, pos, nil
+}
+
+// Result returns the Result tag for the final position, or ResultUnknown if
+// the game is not over on the board.
+func (p *Position) Result() string {
+	switch {
+	case p.winner == Red:
+		return ResultRed
+	case p.winner == Yellow:
+		return ResultYellow
+	case p.IsFull():
+		return ResultDraw
+	}
+	return ResultUnknown
+}
+
+// parseTag reads a line of the form [Name "value"].
+func parseTag(line string) (string, string, error) {
+	if !strings.HasSuffix(line, "]") {
+		return "", "", ErrBadTag
+	}
+	name, quoted, ok := strings.Cut(line[1:len(line)-1], " ")
+	if !ok || name == "" {
+		return "", "", ErrBadTag
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(quoted))
+	if err != nil {
+		return "", "", ErrBadTag
+	}
+	return name, value, nil
+}
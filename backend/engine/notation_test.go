@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoves(t *testing.T) {
+	pos, err := ParseMoves("44 53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatMoves(pos.Moves()); got != "4453" {
+		t.Errorf("round trip gave %q", got)
+	}
+
+	tests := []struct {
+		moves string
+		ply   int
+		err   error
+	}{
+		{"4480", 3, ErrInvalidColumn},
+		{"1111111", 7, ErrColumnFull},
+		{"12121211", 8, ErrGameOver},
+	}
+	for _, tt := range tests {
+		_, err := ParseMoves(tt.moves)
+		var pe *PlyError
+		if !errors.As(err, &pe) || pe.Ply != tt.ply || !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want ply %d: %v", tt.moves, err, tt.ply, tt.err)
+		}
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	r := &Record{
+		Tags:  map[string]string{"Red": "alice", "Yellow": `bob "the builder"`, "Result": ResultRed, "Event": "club"},
+		Moves: []int{0, 1, 0, 1, 0, 1, 0},
+	}
+	text := r.String()
+	want := "[Red \"alice\"]\n[Yellow \"bob \\\"the builder\\\"\"]\n[Result \"1-0\"]\n[Event \"club\"]\n\n1212121\n"
+	if text != want {
+		t.Errorf("got\n%s\nwant\n%s", text, want)
+	}
+
+	got, pos, err := ParseRecord(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != text || pos.Winner() != Red {
+		t.Errorf("round trip gave\n%s", got)
+	}
+}
+
+func TestParseRecordErrors(t *testing.T) {
+	if _, _, err := ParseRecord("[Result \"0-1\"]\n1212121"); err != ErrResultMismatch {
+		t.Errorf("wrong result: got %v", err)
+	}
+	if _, _, err := ParseRecord("[Result \"0-1\"]\n4453"); err != nil {
+		t.Errorf("resigned game: got %v", err)
+	}
+	if _, _, err := ParseRecord("[Red alice]\n4453"); !errors.Is(err, ErrBadTag) {
+		t.Errorf("unquoted tag: got %v", err)
+	}
+	var pe *PlyError
+	if _, _, err := ParseRecord("[Red \"alice\"]\n\n4477777777"); !errors.As(err, &pe) || pe.Ply != 9 {
+		t.Errorf("full column: got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+// Package engine implements the Connect Four rules: dropping discs, legal
+// moves, win detection and undo. It has no dependency on the game server so
+// the server, the bots and offline tools can all share one implementation.
+package engine
+
+import "errors"
+
+const (
+	Rows = 6
+	Cols = 7
+
+	// WinLength is the number of discs in a row needed to win.
+	WinLength = 4
+)
+
+// Disc is the content of a board cell, and also identifies a side.
+type Disc uint8
+
+const (
+	Empty Disc = iota
+	Red
+	Yellow
+)
+
+func (d Disc) String() string {
+	switch d {
+	case Red:
+		return "red"
+	case Yellow:
+		return "yellow"
+	}
+	return ""
+}
+
+// Opponent returns the other side. Empty has no opponent.
+func (d Disc) Opponent() Disc {
+	switch d {
+	case Red:
+		return Yellow
+	case Yellow:
+		return Red
+	}
+	return Empty
+}
+
+// Cell addresses a square on the board. Row 0 is the top row, matching the
+// layout sent to clients.
+type Cell struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+var (
+	ErrInvalidColumn = errors.New("invalid column")
+	ErrColumnFull    = errors.New("column is full")
+	ErrGameOver      = errors.New("game is over")
+	ErrNoMoves       = errors.New("no moves to undo")
+	ErrFloatingDisc  = errors.New("disc is not supported by the one below")
+	ErrInvalidTurn   = errors.New("side to move must be red or yellow")
+)
+
+// Grid is a bare board with no notion of turn or history.
+type Grid [Rows][Cols]Disc
+
+// IsFull reports whether every cell is occupied.
+func (g *Grid) IsFull() bool {
+	for r := range g {
+		for _, cell := range g[r] {
+			if cell == Empty {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Position is a board plus the side to move and the moves played on it.
+// It keeps a Bitboard in step with the cells for constant-time win checks.
+// The zero value is not usable; use NewPosition or FromCells.
+type Position struct {
+	cells   Grid
+	bits    Bitboard
+	heights [Cols]int
+	toMove  Disc
+	history []Cell
+	winner  Disc
+	winLine []Cell
+}
+
+// NewPosition returns the empty board with red to move.
+func NewPosition() *Position {
+	return &Position{bits: NewBitboard(), toMove: Red}
+}
+
+// FromCells builds a position from an arbitrary board. The board must obey
+// gravity but disc counts are not checked, so test and puzzle positions can
+// be set up directly. Moves made before the setup cannot be undone.
+func FromCells(cells Grid, toMove Disc) (*Position, error) {
+	if toMove != Red && toMove != Yellow {
+		return nil, ErrInvalidTurn
+	}
+	p := &Position{cells: cells, toMove: toMove}
+	for c := 0; c < Cols; c++ {
+		h := 0
+		for r := Rows - 1; r >= 0; r-- {
+			if cells[r][c] == Empty {
+				break
+			}
+			h++
+		}
+		for r := Rows - 1 - h; r >= 0; r-- {
+			if cells[r][c] != Empty {
+				return nil, ErrFloatingDisc
+			}
+		}
+		p.heights[c] = h
+	}
+	p.bits = BitboardFromGrid(cells, toMove)
+	if p.bits.Winner() == Empty {
+		return p, nil
+	}
+	for r := 0; r < Rows && p.winner == Empty; r++ {
+		for c := 0; c < Cols; c++ {
+			if line := p.LineThrough(r, c); line != nil {
+				p.winner = cells[r][c]
+				p.winLine = line
+				break
+			}
+		}
+	}
+	return p, nil
+}
+
+// Clone returns an independent copy of the position.
+func (p *Position) Clone() *Position {
+	cp := *p
+	cp.history = append([]Cell(nil), p.history...)
+	cp.winLine = append([]Cell(nil), p.winLine...)
+	return &cp
+}
+
+// At returns the disc at the given cell.
+func (p *Position) At(row, col int) Disc {
+	return p.cells[row][col]
+}
+
+// Cells returns a copy of the board.
+func (p *Position) Cells() Grid {
+	return p.cells
+}
+
+// ToMove returns the side whose turn it is.
+func (p *Position) ToMove() Disc {
+	return p.toMove
+}
+
+// Height returns the number of discs in a column.
+func (p *Position) Height(col int) int {
+	return p.heights[col]
+}
+
+// Discs returns the number of discs on the board.
+func (p *Position) Discs() int {
+	n := 0
+	for _, h := range p.heights {
+		n += h
+	}
+	return n
+}
+
+// Moves returns the columns played since the position was created.
+func (p *Position) Moves() []int {
+	cols := make([]int, len(p.history))
+	for i, cell := range p.history {
+		cols[i] = cell.Col
+	}
+	return cols
+}
+
+// CanPlay reports whether a disc can be dropped in col.
+func (p *Position) CanPlay(col int) bool {
+	return col >= 0 && col < Cols && p.heights[col] < Rows && p.winner == Empty
+}
+
+// LegalMoves returns the playable columns, left to right.
+func (p *Position) LegalMoves() []int {
+	var cols []int
+	for c := 0; c < Cols; c++ {
+		if p.CanPlay(c) {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// Play drops a disc for the side to move in col and returns the row it
+// landed in.
+func (p *Position) Play(col int) (int, error) {
+	if col < 0 || col >= Cols {
+		return -1, ErrInvalidColumn
+	}
+	if p.winner != Empty {
+		return -1, ErrGameOver
+	}
+	if p.heights[col] >= Rows {
+		return -1, ErrColumnFull
+	}
+	row := Rows - 1 - p.heights[col]
+	p.cells[row][col] = p.toMove
+	p.heights[col]++
+	p.bits.Play(col)
+	p.history = append(p.history, Cell{Row: row, Col: col})
+	if p.bits.InFour(row, col) {
+		p.winner = p.toMove
+		p.winLine = p.LineThrough(row, col)
+	}
+	p.toMove = p.toMove.Opponent()
+	return row, nil
+}
+
+// Undo takes back the last move played on this position.
+func (p *Position) Undo() error {
+	if len(p.history) == 0 {
+		return ErrNoMoves
+	}
+	last := p.history[len(p.history)-1]
+	p.history = p.history[:len(p.history)-1]
+	p.toMove = p.cells[last.Row][last.Col]
+	p.cells[last.Row][last.Col] = Empty
+	p.heights[last.Col]--
+	p.bits.Undo(last.Col)
+	p.winner = Empty
+	p.winLine = nil
+	return nil
+}
+
+// Winner returns the side that has connected four, or Empty.
+func (p *Position) Winner() Disc {
+	return p.winner
+}
+
+// WinLine returns the cells of the winning line, or nil if nobody has won.
+func (p *Position) WinLine() []Cell {
+	return p.winLine
+}
+
+// IsFull reports whether every column is full.
+func (p *Position) IsFull() bool {
+	for _, h := range p.heights {
+		if h < Rows {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDraw reports whether the board is full with no winner.
+func (p *Position) IsDraw() bool {
+	return p.winner == Empty && p.IsFull()
+}
+
+// IsOver reports whether the game has ended.
+func (p *Position) IsOver() bool {
+	return p.winner != Empty || p.IsFull()
+}
+
+var directions = [4][2]int{
+	{0, 1},  // horizontal
+	{1, 0},  // vertical
+	{1, 1},  // diagonal down-right
+	{1, -1}, // diagonal down-left
+}
+
+// LineThrough returns the winning line through the given cell, or nil.
+func (p *Position) LineThrough(row, col int) []Cell {
+	return p.cells.LineThrough(row, col)
+}
+
+// LineThrough returns the longest run of four or more same-coloured discs
+// passing through the given cell, or nil if there is none. Gravity is not
+// assumed, so it works on any grid.
+func (g *Grid) LineThrough(row, col int) []Cell {
+	color := g[row][col]
+	if color == Empty {
+		return nil
+	}
+	var best []Cell
+	for _, dir := range directions {
+		line := []Cell{{Row: row, Col: col}}
+		for i := 1; ; i++ {
+			r, c := row-dir[0]*i, col-dir[1]*i
+			if !onBoard(r, c) || g[r][c] != color {
+				break
+			}
+			line = append([]Cell{{Row: r, Col: c}}, line...)
+		}
+		for i := 1; ; i++ {
+			r, c := row+dir[0]*i, col+dir[1]*i
+			if !onBoard(r, c) || g[r][c] != color {
+				break
+			}
+			line = append(line, Cell{Row: r, Col: c})
+		}
+		if len(line) >= WinLength && len(line) > len(best) {
+			best = line
+		}
+	}
+	return best
+}
+
+func onBoard(row, col int) bool {
+	return row >= 0 && row < Rows && col >= 0 && col < Cols
+}
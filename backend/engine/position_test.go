@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func play(t *testing.T, p *Position, cols ...int) {
+	t.Helper()
+	for _, c := range cols {
+		if _, err := p.Play(c); err != nil {
+			t.Fatalf("play %d: %v", c, err)
+		}
+	}
+}
+
+func TestPlayStacksDiscs(t *testing.T) {
+	p := NewPosition()
+	row, err := p.Play(3)
+	if err != nil || row != Rows-1 {
+		t.Fatalf("first disc landed at row %d (%v), want %d", row, err, Rows-1)
+	}
+	row, _ = p.Play(3)
+	if row != Rows-2 {
+		t.Errorf("second disc landed at row %d, want %d", row, Rows-2)
+	}
+	if p.At(Rows-1, 3) != Red || p.At(Rows-2, 3) != Yellow {
+		t.Error("discs should alternate red, yellow")
+	}
+	if p.ToMove() != Red {
+		t.Errorf("red should be to move, got %v", p.ToMove())
+	}
+}
+
+func TestPlayErrors(t *testing.T) {
+	p := NewPosition()
+	if _, err := p.Play(-1); err != ErrInvalidColumn {
+		t.Errorf("got %v, want ErrInvalidColumn", err)
+	}
+	if _, err := p.Play(Cols); err != ErrInvalidColumn {
+		t.Errorf("got %v, want ErrInvalidColumn", err)
+	}
+	play(t, p, 0, 0, 0, 0, 0, 0)
+	if _, err := p.Play(0); err != ErrColumnFull {
+		t.Errorf("got %v, want ErrColumnFull", err)
+	}
+	if reflect.DeepEqual(p.LegalMoves(), []int{0, 1, 2, 3, 4, 5, 6}) {
+		t.Error("full column should not be a legal move")
+	}
+}
+
+func TestWinnerAndWinLine(t *testing.T) {
+	p := NewPosition()
+	play(t, p, 0, 0, 1, 1, 2, 2, 3)
+	if p.Winner() != Red {
+		t.Fatalf("winner = %v, want red", p.Winner())
+	}
+	want := []Cell{{5, 0}, {5, 1}, {5, 2}, {5, 3}}
+	if !reflect.DeepEqual(p.WinLine(), want) {
+		t.Errorf("win line = %v, want %v", p.WinLine(), want)
+	}
+	if _, err := p.Play(4); err != ErrGameOver {
+		t.Errorf("got %v, want ErrGameOver", err)
+	}
+	if len(p.LegalMoves()) != 0 {
+		t.Error("no moves should be legal after a win")
+	}
+}
+
+func TestDiagonalWin(t *testing.T) {
+	p := NewPosition()
+	play(t, p, 0, 1, 1, 2, 2, 3, 2, 3, 3, 6, 3)
+	if p.Winner() != Red {
+		t.Fatalf("winner = %v, want red", p.Winner())
+	}
+	if len(p.WinLine()) != 4 {
+		t.Errorf("win line = %v, want four cells", p.WinLine())
+	}
+}
+
+func TestUndo(t *testing.T) {
+	p := NewPosition()
+	if err := p.Undo(); err != ErrNoMoves {
+		t.Errorf("got %v, want ErrNoMoves", err)
+	}
+	play(t, p, 0, 0, 1, 1, 2, 2, 3)
+	if err := p.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Winner() != Empty || p.WinLine() != nil {
+		t.Error("undoing the winning move should clear the winner")
+	}
+	if p.ToMove() != Red || p.At(Rows-1, 3) != Empty {
+		t.Error("undo should restore the cell and side to move")
+	}
+	if !reflect.DeepEqual(p.Moves(), []int{0, 0, 1, 1, 2, 2}) {
+		t.Errorf("moves = %v", p.Moves())
+	}
+}
+
+func TestDraw(t *testing.T) {
+	// Columns alternate three-and-three stacks, which never line up four.
+	var cells Grid
+	for c := 0; c < Cols; c++ {
+		for r := 0; r < Rows; r++ {
+			if (c%2 == 0) == (r < Rows/2) {
+				cells[r][c] = Yellow
+			} else {
+				cells[r][c] = Red
+			}
+		}
+	}
+	p, err := FromCells(cells, Red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsFull() || !p.IsDraw() || p.Winner() != Empty {
+		t.Fatalf("expected a drawn full board, winner=%v", p.Winner())
+	}
+	if len(p.LegalMoves()) != 0 {
+		t.Error("full board should have no legal moves")
+	}
+}
+
+func TestFromCells(t *testing.T) {
+	var cells Grid
+	cells[5][0], cells[5][1], cells[5][2] = Red, Red, Red
+	p, err := FromCells(cells, Yellow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Height(0) != 1 || p.Discs() != 3 || p.ToMove() != Yellow {
+		t.Error("position not set up from cells")
+	}
+	if err := p.Undo(); err != ErrNoMoves {
+		t.Errorf("setup discs should not be undoable, got %v", err)
+	}
+
+	cells[2][4] = Yellow
+	if _, err := FromCells(cells, Red); err != ErrFloatingDisc {
+		t.Errorf("got %v, want ErrFloatingDisc", err)
+	}
+	if _, err := FromCells(Grid{}, Empty); err != ErrInvalidTurn {
+		t.Errorf("got %v, want ErrInvalidTurn", err)
+	}
+
+	cells[2][4] = Empty
+	cells[5][3] = Red
+	p, _ = FromCells(cells, Yellow)
+	if p.Winner() != Red {
+		t.Error("existing four in a row should be detected")
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	p := NewPosition()
+	play(t, p, 3)
+	cp := p.Clone()
+	play(t, cp, 3)
+	if p.Height(3) != 1 || cp.Height(3) != 2 {
+		t.Error("clone should not share state")
+	}
+}
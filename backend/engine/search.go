@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"context"
+	"math/bits"
+)
+
+// WinScore is the score of a won position. Wins found sooner score higher:
+// a win at ply n scores WinScore - n.
+const WinScore = 1_000_000
+
+// Heuristic weights for Evaluate.
+const (
+	threeWeight  = 50
+	twoWeight    = 5
+	centerWeight = 3
+)
+
+// MoveOrder lists the columns from the centre outwards. Central columns take
+// part in more lines, so trying them first gives alpha-beta earlier cutoffs.
+var MoveOrder = [Cols]int{3, 2, 4, 1, 5, 0, 6}
+
+// windows holds a mask for every line of four cells on the board.
+var windows = func() []uint64 {
+	var ws []uint64
+	for r := 0; r < Rows; r++ {
+		for c := 0; c < Cols; c++ {
+			for _, dir := range directions {
+				endR, endC := r+dir[0]*(WinLength-1), c+dir[1]*(WinLength-1)
+				if !onBoard(endR, endC) {
+					continue
+				}
+				var w uint64
+				for i := 0; i < WinLength; i++ {
+					w |= cellBit(r+dir[0]*i, c+dir[1]*i)
+				}
+				ws = append(ws, w)
+			}
+		}
+	}
+	return ws
+}()
+
+var centerMask = func() uint64 {
+	var m uint64
+	for r := 0; r < Rows; r++ {
+		m |= cellBit(r, Cols/2)
+	}
+	return m
+}()
+
+// Evaluate scores a position from the point of view of the side to move by
+// counting lines of four that only one side can still complete: three discs
+// and a gap are worth much more than two, and central discs get a bonus.
+func Evaluate(b *Bitboard) int {
+	mine, theirs := b.Discs(b.toMove), b.Discs(b.toMove.Opponent())
+	score := centerWeight * (bits.OnesCount64(mine&centerMask) - bits.OnesCount64(theirs&centerMask))
+	for _, w := range windows {
+		own, opp := bits.OnesCount64(w&mine), bits.OnesCount64(w&theirs)
+		switch {
+		case opp == 0:
+			score += lineScore(own)
+		case own == 0:
+			score -= lineScore(opp)
+		}
+	}
+	return score
+}
+
+func lineScore(n int) int {
+	switch n {
+	case 3:
+		return threeWeight
+	case 2:
+		return twoWeight
+	}
+	return 0
+}
+
+// Search runs a depth-limited negamax with alpha-beta pruning and returns the
+// best column for the side to move with its score. It returns -1 if there is
+// no legal move.
+func Search(b Bitboard, depth int) (int, int) {
+	col, score, _ := SearchContext(context.Background(), b, depth)
+	return col, score
+}
+
+// SearchContext is Search that gives up with the context's error once ctx is
+// done.
+func SearchContext(ctx context.Context, b Bitboard, depth int) (int, int, error) {
+	if depth < 1 {
+		depth = 1
+	}
+	s := &searcher{ctx: ctx}
+	best, bestScore := -1, -WinScore-1
+	alpha, beta := -WinScore-1, WinScore+1
+	for _, col := range MoveOrder {
+		if !b.CanPlay(col) {
+			continue
+		}
+		if b.IsWinningMove(col) {
+			return col, WinScore - 1, nil
+		}
+		b.Play(col)
+		score := -s.negamax(&b, depth-1, -beta, -alpha, 1)
+		b.Undo(col)
+		if s.aborted {
+			return -1, 0, ctx.Err()
+		}
+		if score > bestScore {
+			best, bestScore = col, score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return best, bestScore, nil
+}
+
+type searcher struct {
+	ctx     context.Context
+	nodes   uint64
+	aborted bool
+}
+
+func (s *searcher) negamax(b *Bitboard, depth, alpha, beta, ply int) int {
+	s.nodes++
+	if s.nodes&0xfff == 0 && s.ctx.Err() != nil {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+	for col := 0; col < Cols; col++ {
+		if b.IsWinningMove(col) {
+			return WinScore - ply - 1
+		}
+	}
+	if b.IsFull() {
+		return 0
+	}
+	if depth == 0 {
+		return Evaluate(b)
+	}
+	for _, col := range MoveOrder {
+		if !b.CanPlay(col) {
+			continue
+		}
+		b.Play(col)
+		score := -s.negamax(b, depth-1, -beta, -alpha, ply+1)
+		b.Undo(col)
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+func bitboardOf(t *testing.T, toMove Disc, discs map[Cell]Disc) Bitboard {
+	t.Helper()
+	var g Grid
+	for cell, d := range discs {
+		g[cell.Row][cell.Col] = d
+	}
+	p, err := FromCells(g, toMove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Bitboard()
+}
+
+func TestSearchTakesWin(t *testing.T) {
+	b := bitboardOf(t, Yellow, map[Cell]Disc{
+		{5, 0}: Yellow, {5, 1}: Yellow, {5, 2}: Yellow,
+		{4, 0}: Red, {4, 1}: Red, {4, 2}: Red,
+	})
+	if col, score := Search(b, 5); col != 3 || score <= WinScore-Rows*Cols {
+		t.Errorf("got col %d score %d, want winning col 3", col, score)
+	}
+}
+
+func TestSearchBlocksThreat(t *testing.T) {
+	b := bitboardOf(t, Yellow, map[Cell]Disc{
+		{5, 0}: Red, {5, 1}: Red, {5, 2}: Red,
+	})
+	if col, _ := Search(b, 5); col != 3 {
+		t.Errorf("got col %d, want block at 3", col)
+	}
+}
+
+// Red threatens to complete an open three on the bottom row, which would
+// leave two winning cells. The one-ply bot stacked on the centre instead.
+func TestSearchAvoidsTwoMoveTrap(t *testing.T) {
+	b := bitboardOf(t, Yellow, map[Cell]Disc{
+		{5, 2}: Red, {5, 3}: Red, {4, 3}: Yellow, {5, 6}: Red, {4, 6}: Yellow,
+	})
+	if col, _ := Search(b, 6); col != 1 && col != 4 {
+		t.Errorf("got col %d, want 1 or 4 to stop the open three", col)
+	}
+}
+
+func TestSearchFindsForcedWin(t *testing.T) {
+	// Red to move can set up the open three itself.
+	b := bitboardOf(t, Red, map[Cell]Disc{
+		{5, 2}: Red, {5, 3}: Red, {4, 3}: Yellow, {4, 2}: Yellow,
+	})
+	col, score := Search(b, 6)
+	if col != 1 && col != 4 {
+		t.Errorf("got col %d, want 1 or 4", col)
+	}
+	if score < WinScore-Rows*Cols {
+		t.Errorf("score %d should be a forced win", score)
+	}
+}
+
+func TestSearchNoMoves(t *testing.T) {
+	var g Grid
+	for r := range g {
+		for c := range g[r] {
+			if (c%2 == 0) == (r < Rows/2) {
+				g[r][c] = Yellow
+			} else {
+				g[r][c] = Red
+			}
+		}
+	}
+	if col, _ := Search(BitboardFromGrid(g, Red), 4); col != -1 {
+		t.Errorf("got col %d on a full board, want -1", col)
+	}
+}
+
+func TestEvaluateIsSymmetric(t *testing.T) {
+	b := bitboardOf(t, Yellow, map[Cell]Disc{{5, 3}: Red})
+	if Evaluate(&b) >= 0 {
+		t.Error("side to move should be worse off after the opponent takes the centre")
+	}
+	b = bitboardOf(t, Red, map[Cell]Disc{{5, 3}: Red, {4, 3}: Yellow})
+	if Evaluate(&b) != 0 {
+		t.Errorf("mirror-equal position should evaluate to 0, got %d", Evaluate(&b))
+	}
+}
+
+func TestSearchContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := SearchContext(ctx, NewBitboard(), 12); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
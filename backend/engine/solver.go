@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"context"
+	"math/bits"
+	"sync/atomic"
+)
+
+// Solver scores are from the side to move's point of view: 0 is a draw, a
+// positive score is a win and a negative one a loss. The magnitude counts
+// how early the game ends: a side that wins with its k-th disc scores
+// 22 - k, so faster wins and slower losses score higher.
+const (
+	MinScore = -(Rows*Cols)/2 + 3
+	MaxScore = (Rows*Cols+1)/2 - 3
+)
+
+var boardMask = bottomMask * (1<<Rows - 1)
+
+func columnMask(col int) uint64 {
+	return (1<<Rows - 1) << (col * colBits)
+}
+
+// node is the solver's internal position: the side to move's discs and the
+// occupied mask, in the same layout as Bitboard.
+type node struct {
+	current, mask uint64
+	moves         int
+}
+
+func nodeOf(b *Bitboard) node {
+	return node{current: b.Discs(b.toMove), mask: b.Mask(), moves: b.moves}
+}
+
+func (n node) key() uint64 {
+	return n.current + n.mask + bottomMask
+}
+
+func (n node) play(move uint64) node {
+	return node{current: n.current ^ n.mask, mask: n.mask | move, moves: n.moves + 1}
+}
+
+func (n node) possible() uint64 {
+	return (n.mask + bottomMask) & boardMask
+}
+
+func (n node) canWinNext() bool {
+	return winningCells(n.current, n.mask)&n.possible() != 0
+}
+
+// nonLosingMoves returns the moves that do not hand the opponent an
+// immediate win. It assumes the side to move cannot win at once.
+func (n node) nonLosingMoves() uint64 {
+	possible := n.possible()
+	opponentWins := winningCells(n.current^n.mask, n.mask)
+	if forced := possible & opponentWins; forced != 0 {
+		if forced&(forced-1) != 0 {
+			return 0 // two threats at once cannot both be blocked
+		}
+		possible = forced
+	}
+	// Never play directly below a cell the opponent would win on.
+	return possible &^ (opponentWins >> 1)
+}
+
+// moveScore rates a move by how many winning cells it creates.
+func (n node) moveScore(move uint64) int {
+	return bits.OnesCount64(winningCells(n.current|move, n.mask))
+}
+
+// winningCells returns the empty cells that would complete four for the
+// side owning discs.
+func winningCells(discs, mask uint64) uint64 {
+	// vertical
+	r := (discs << 1) & (discs << 2) & (discs << 3)
+
+	// horizontal
+	p := (discs << colBits) & (discs << (2 * colBits))
+	r |= p & (discs << (3 * colBits))
+	r |= p & (discs >> colBits)
+	p = (discs >> colBits) & (discs >> (2 * colBits))
+	r |= p & (discs << colBits)
+	r |= p & (discs >> (3 * colBits))
+
+	// diagonal going down to the right
+	p = (discs << (colBits - 1)) & (discs << (2 * (colBits - 1)))
+	r |= p & (discs << (3 * (colBits - 1)))
+	r |= p & (discs >> (colBits - 1))
+	p = (discs >> (colBits - 1)) & (discs >> (2 * (colBits - 1)))
+	r |= p & (discs << (colBits - 1))
+	r |= p & (discs >> (3 * (colBits - 1)))
+
+	// diagonal going up to the right
+	p = (discs << (colBits + 1)) & (discs << (2 * (colBits + 1)))
+	r |= p & (discs << (3 * (colBits + 1)))
+	r |= p & (discs >> (colBits + 1))
+	p = (discs >> (colBits + 1)) & (discs >> (2 * (colBits + 1)))
+	r |= p & (discs << (colBits + 1))
+	r |= p & (discs >> (3 * (colBits + 1)))
+
+	return r & (boardMask ^ mask)
+}
+
+// Table is a transposition table of upper bounds on position scores. Each
+// slot packs the full position key and the bound into one word, read and
+// written atomically, so a Table can be shared by concurrent solvers.
+type Table struct {
+	slots []uint64
+	shift uint
+}
+
+// NewTable allocates a table with 2^sizeBits slots of 8 bytes each.
+func NewTable(sizeBits uint) *Table {
+	return &Table{slots: make([]uint64, 1<<sizeBits), shift: 64 - sizeBits}
+}
+
+func (t *Table) index(key uint64) uint64 {
+	return (key * 0x9e3779b97f4a7c15) >> t.shift
+}
+
+func (t *Table) get(key uint64) int {
+	slot := atomic.LoadUint64(&t.slots[t.index(key)])
+	if slot>>8 != key {
+		return 0
+	}
+	return int(slot & 0xff)
+}
+
+func (t *Table) put(key uint64, val int) {
+	atomic.StoreUint64(&t.slots[t.index(key)], key<<8|uint64(val))
+}
+
+// Solver computes exact scores with a negamax search using null-window
+// probes, a transposition table and threat-based move ordering. A Solver is
+// not safe for concurrent use, but several solvers may share a Table.
+type Solver struct {
+	table   *Table
+	ctx     context.Context
+	aborted bool
+	Nodes   uint64
+}
+
+// NewSolver returns a solver backed by table.
+func NewSolver(table *Table) *Solver {
+	return &Solver{table: table}
+}
+
+// Solve returns the exact score of the position. It gives up with the
+// context's error once ctx is done.
+func (s *Solver) Solve(ctx context.Context, b Bitboard) (int, error) {
+	if b.Winner() != Empty {
+		return 0, ErrGameOver
+	}
+	if b.IsFull() {
+		return 0, nil
+	}
+	s.ctx, s.aborted = ctx, false
+	n := nodeOf(&b)
+	if n.canWinNext() {
+		return (Rows*Cols + 1 - n.moves) / 2, nil
+	}
+
+	// Narrow the score window with null-window probes, aiming near zero
+	// first since most positions are decided by the win/draw/loss probes.
+	min, max := -(Rows*Cols-n.moves)/2, (Rows*Cols+1-n.moves)/2
+	for min < max {
+		med := min + (max-min)/2
+		if med <= 0 && min/2 < med {
+			med = min / 2
+		} else if med >= 0 && max/2 > med {
+			med = max / 2
+		}
+		r := s.negamax(n, med, med+1)
+		if s.aborted {
+			return 0, ctx.Err()
+		}
+		if r <= med {
+			max = r
+		} else {
+			min = r
+		}
+	}
+	return min, nil
+}
+
+// MoveScore is the exact score of playing a column.
+type MoveScore struct {
+	Col   int `json:"col"`
+	Score int `json:"score"`
+}
+
+// Plies converts a solver score for the side to move, in a position with
+// the given number of discs, into the number of plies left until the game is
+// decided, counting the next move. A draw returns 0.
+func Plies(moves, score int) int {
+	if score == 0 {
+		return 0
+	}
+	abs, parity := score, moves+1 // the winner's last disc
+	if score < 0 {
+		abs, parity = -score, moves
+	}
+	end := Rows*Cols + 2 - 2*abs
+	if (end-parity)%2 != 0 {
+		end--
+	}
+	return end - moves
+}
+
+// Analyze scores each legal move for the side to move, centre columns
+// first. If ctx ends early it returns the moves solved so far along with the
+// context's error.
+func (s *Solver) Analyze(ctx context.Context, b Bitboard) ([]MoveScore, error) {
+	if b.Winner() != Empty {
+		return nil, ErrGameOver
+	}
+	var scores []MoveScore
+	for _, col := range MoveOrder {
+		if !b.CanPlay(col) {
+			continue
+		}
+		if b.IsWinningMove(col) {
+			scores = append(scores, MoveScore{Col: col, Score: (Rows*Cols + 1 - b.moves) / 2})
+			continue
+		}
+		child := b
+		child.Play(col)
+		score, err := s.Solve(ctx, child)
+		if err != nil {
+			return scores, err
+		}
+		scores = append(scores, MoveScore{Col: col, Score: -score})
+	}
+	return scores, nil
+}
+
+func (s *Solver) negamax(n node, alpha, beta int) int {
+	s.Nodes++
+	if s.Nodes&0xfff == 0 && s.ctx.Err() != nil {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+
+	next := n.nonLosingMoves()
+	if next == 0 {
+		return -(Rows*Cols - n.moves) / 2
+	}
+	if n.moves >= Rows*Cols-2 {
+		return 0
+	}
+
+	min := -(Rows*Cols - 2 - n.moves) / 2
+	if alpha < min {
+		alpha = min
+		if alpha >= beta {
+			return alpha
+		}
+	}
+	max := (Rows*Cols - 1 - n.moves) / 2
+	if val := s.table.get(n.key()); val != 0 {
+		max = val + MinScore - 1
+	}
+	if beta > max {
+		beta = max
+		if alpha >= beta {
+			return beta
+		}
+	}
+
+	var moves [Cols]uint64
+	var scores [Cols]int
+	count := 0
+	for _, col := range MoveOrder {
+		move := next & columnMask(col)
+		if move == 0 {
+			continue
+		}
+		score := n.moveScore(move)
+		// Insertion sort, best first; ties keep centre-first order.
+		i := count
+		for ; i > 0 && scores[i-1] < score; i-- {
+			moves[i], scores[i] = moves[i-1], scores[i-1]
+		}
+		moves[i], scores[i] = move, score
+		count++
+	}
+
+	for _, move := range moves[:count] {
+		score := -s.negamax(n.play(move), -beta, -alpha)
+		if s.aborted {
+			return 0
+		}
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	s.table.put(n.key(), alpha-MinScore+1)
+	return alpha
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// bruteForce scores a position by plain exhaustive negamax, using the same
+// scale as the solver.
+func bruteForce(b Bitboard) int {
+	if b.IsFull() {
+		return 0
+	}
+	for col := 0; col < Cols; col++ {
+		if b.IsWinningMove(col) {
+			return (Rows*Cols + 1 - b.Moves()) / 2
+		}
+	}
+	best := -Rows * Cols
+	for col := 0; col < Cols; col++ {
+		if !b.CanPlay(col) {
+			continue
+		}
+		child := b
+		child.Play(col)
+		if score := -bruteForce(child); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// randomPosition plays random moves until discs are on the board, retrying
+// games that end early.
+func randomPosition(rng *rand.Rand, discs int) Bitboard {
+	for {
+		b := NewBitboard()
+		for b.Moves() < discs && b.Winner() == Empty {
+			var moves []int
+			for col := 0; col < Cols; col++ {
+				if b.CanPlay(col) {
+					moves = append(moves, col)
+				}
+			}
+			b.Play(moves[rng.Intn(len(moves))])
+		}
+		if b.Winner() == Empty {
+			return b
+		}
+	}
+}
+
+func TestSolverMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	s := NewSolver(NewTable(16))
+	for i := 0; i < 300; i++ {
+		b := randomPosition(rng, 32+rng.Intn(6))
+		want := bruteForce(b)
+		got, err := s.Solve(context.Background(), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("position %d: solver %d, brute force %d", i, got, want)
+		}
+	}
+}
+
+func TestSolverScores(t *testing.T) {
+	s := NewSolver(NewTable(16))
+	b := NewBitboard()
+	for _, col := range []int{0, 1, 0, 1, 0, 1} {
+		b.Play(col)
+	}
+	score, err := s.Solve(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Red wins with its fourth disc.
+	if want := (Rows*Cols + 1 - 6) / 2; score != want {
+		t.Errorf("score = %d, want %d", score, want)
+	}
+
+	b.Play(0)
+	if _, err := s.Solve(context.Background(), b); err != ErrGameOver {
+		t.Errorf("got %v, want ErrGameOver", err)
+	}
+}
+
+func TestAnalyzeRanksMoves(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	s := NewSolver(NewTable(16))
+	for i := 0; i < 50; i++ {
+		b := randomPosition(rng, 30)
+		scores, err := s.Analyze(context.Background(), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		best := MinScore - 10
+		for _, ms := range scores {
+			if ms.Score > best {
+				best = ms.Score
+			}
+		}
+		want, _ := s.Solve(context.Background(), b)
+		if best != want {
+			t.Fatalf("position %d: best move scores %d, position scores %d", i, best, want)
+		}
+	}
+}
+
+func TestSolverHonoursDeadline(t *testing.T) {
+	s := NewSolver(NewTable(16))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.Solve(ctx, NewBitboard()); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("solver overran its deadline by %v", elapsed)
+	}
+}
+
+// With both sides playing their best-scored move, a decided game must end
+// exactly when Plies says it will.
+func TestPliesMatchesPerfectPlay(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	s := NewSolver(NewTable(16))
+	for i := 0; i < 50; i++ {
+		b := randomPosition(rng, 30)
+		score, _ := s.Solve(context.Background(), b)
+		want := Plies(b.Moves(), score)
+		plies := 0
+		for b.Winner() == Empty && !b.IsFull() {
+			scores, err := s.Analyze(context.Background(), b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			best := scores[0]
+			for _, ms := range scores {
+				if ms.Score > best.Score {
+					best = ms
+				}
+			}
+			b.Play(best.Col)
+			plies++
+		}
+		if score == 0 {
+			if b.Winner() != Empty {
+				t.Fatalf("position %d: drawn position was won", i)
+			}
+		} else if plies != want {
+			t.Fatalf("position %d: score %d ended after %d plies, Plies says %d", i, score, plies, want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// GameRecord is a game's metadata plus its moves in order. Winner is the
+// winning username; Result is the winning colour, "draw", or empty while the
+// game is in progress.
+type GameRecord struct {
+	ID          string     `json:"id"`
+	Player1     string     `json:"player1"`
+	Player2     string     `json:"player2"`
+	Winner      string     `json:"winner"`
+	Result      string     `json:"result"`
+	StartTime   time.Time  `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	IsBot       bool       `json:"is_bot"`
+	Difficulty  Difficulty `json:"difficulty,omitempty"`
+	TimeControl string     `json:"time_control"`
+	Termination string     `json:"termination,omitempty"`
+	Moves       []Move     `json:"moves"`
+}
+
+// LiveGame summarises a game in progress for the lobby.
+type LiveGame struct {
+	ID            string     `json:"id"`
+	Player1       string     `json:"player1"`
+	Player2       string     `json:"player2"`
+	Moves         int        `json:"moves"`
+	CurrentPlayer Color      `json:"current_player"`
+	IsBot         bool       `json:"is_bot"`
+	Difficulty    Difficulty `json:"difficulty,omitempty"`
+	Spectators    int        `json:"spectators"`
+	StartTime     time.Time  `json:"start_time"`
+}
+
+// LiveGamesPage is one page of live games, newest first.
+type LiveGamesPage struct {
+	Games   []LiveGame `json:"games"`
+	Total   int        `json:"total"`
+	Page    int        `json:"page"`
+	PerPage int        `json:"per_page"`
+}
+
+// handleGames routes the /games/ endpoints:
+//
+//	GET  /games/live
+//	GET  /games/{id}
+//	GET  /games/{id}/analysis
+//	GET  /games/{id}/notation
+//	GET  /games/{id}/chat
+//	POST /games/import
+func (gs *GameServer) handleGames(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/games/"), "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "import":
+		gs.importGame(w, r)
+		return
+	case r.Method != http.MethodGet || parts[0] == "":
+	case len(parts) == 1 && parts[0] == "live":
+		gs.getLiveGames(w, r)
+		return
+	case len(parts) == 1:
+		gs.getGame(w, parts[0])
+		return
+	case len(parts) == 2 && parts[1] == "analysis":
+		gs.getAnalysis(w, parts[0])
+		return
+	case len(parts) == 2 && parts[1] == "notation":
+		gs.getNotation(w, r, parts[0])
+		return
+	case len(parts) == 2 && parts[1] == "chat":
+		gs.getChat(w, parts[0])
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+}
+
+// getLiveGames serves GET /games/live?page=1&per_page=20.
+func (gs *GameServer) getLiveGames(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPageSize
+	} else if perPage > maxPageSize {
+		perPage = maxPageSize
+	}
+
+	live := gs.liveGames()
+	res := LiveGamesPage{Games: []LiveGame{}, Total: len(live), Page: page, PerPage: perPage}
+	if start := (page - 1) * perPage; start < len(live) {
+		end := start + perPage
+		if end > len(live) {
+			end = len(live)
+		}
+		res.Games = live[start:end]
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// liveGames lists the games in progress, newest first.
+func (gs *GameServer) liveGames() []LiveGame {
+	gs.mutex.RLock()
+	games := make([]*GameState, 0, len(gs.games))
+	for _, game := range gs.games {
+		games = append(games, game)
+	}
+	gs.mutex.RUnlock()
+
+	var live []LiveGame
+	for _, game := range games {
+		game.mutex.RLock()
+		if game.Winner == "" && !game.Imported {
+			lg := LiveGame{
+				ID: game.ID, Player1: game.Player1.Username, Moves: len(game.Moves),
+				CurrentPlayer: game.CurrentPlayer, IsBot: game.IsBot, Difficulty: game.Difficulty,
+				Spectators: len(game.Spectators), StartTime: game.StartTime,
+			}
+			if game.Player2 != nil {
+				lg.Player2 = game.Player2.Username
+			}
+			live = append(live, lg)
+		}
+		game.mutex.RUnlock()
+	}
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].StartTime.Equal(live[j].StartTime) {
+			return live[i].StartTime.After(live[j].StartTime)
+		}
+		return live[i].ID < live[j].ID
+	})
+	return live
+}
+
+func (gs *GameServer) getGame(w http.ResponseWriter, gameID string) {
+	rec, err := gs.loadGame(gameID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "game not found"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Error loading game:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "could not load game"})
+		return
+	}
+	json.NewEncoder(w).Encode(rec)
+}
+
+// loadGame returns a game from memory if the server still holds it, and
+// from the database otherwise. It returns sql.ErrNoRows if neither has it.
+func (gs *GameServer) loadGame(gameID string) (*GameRecord, error) {
+	gs.mutex.RLock()
+	game := gs.games[gameID]
+	gs.mutex.RUnlock()
+	if game != nil {
+		game.mutex.RLock()
+		defer game.mutex.RUnlock()
+		return recordOf(game), nil
+	}
+	if gs.db == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	rec := &GameRecord{ID: gameID, Moves: []Move{}}
+	var winner, difficulty, timeControl, termination sql.NullString
+	err := gs.db.QueryRow(`
+		SELECT player1, player2, winner, start_time, end_time, is_bot, difficulty, time_control, termination
+		FROM games WHERE id = $1
+	`, gameID).Scan(&rec.Player1, &rec.Player2, &winner, &rec.StartTime, &rec.EndTime, &rec.IsBot, &difficulty,
+		&timeControl, &termination)
+	if err != nil {
+		return nil, err
+	}
+	rec.Winner, rec.Difficulty = winner.String, Difficulty(difficulty.String)
+	rec.TimeControl, rec.Termination = timeControl.String, termination.String
+	if rec.TimeControl == "" {
+		rec.TimeControl = "-"
+	}
+	switch {
+	case rec.EndTime == nil:
+	case rec.Winner == "":
+		rec.Result = "draw"
+	case rec.Winner == rec.Player1:
+		rec.Result = string(Red)
+	default:
+		rec.Result = string(Yellow)
+	}
+
+	rows, err := gs.db.Query(`
+		SELECT ply, col, row, color, played_at FROM moves WHERE game_id = $1 ORDER BY ply
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m Move
+		if err := rows.Scan(&m.Ply, &m.Column, &m.Row, &m.Color, &m.Time); err != nil {
+			return nil, err
+		}
+		rec.Moves = append(rec.Moves, m)
+	}
+	return rec, rows.Err()
+}
+
+// recordOf snapshots a game held in memory. The caller holds game.mutex.
+func recordOf(game *GameState) *GameRecord {
+	rec := &GameRecord{
+		ID: game.ID, Player1: game.Player1.Username, Result: game.Winner,
+		StartTime: game.StartTime, EndTime: game.EndTime, IsBot: game.IsBot, Difficulty: game.Difficulty,
+		TimeControl: game.TimeControl.String(), Termination: game.Termination,
+		Moves: append([]Move{}, game.Moves...),
+	}
+	if game.Player2 != nil {
+		rec.Player2 = game.Player2.Username
+	}
+	switch Color(game.Winner) {
+	case Red:
+		rec.Winner = rec.Player1
+	case Yellow:
+		rec.Winner = rec.Player2
+	}
+	return rec
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"connect4/bot"
+	"connect4/engine"
+)
+
+const (
+	hintBurst  = 20 // hints one address may ask for within hintWindow
+	hintWindow = time.Minute
+)
+
+var errTooManyHints = errors.New("too many hints - try again in a minute")
+
+// Hint is the engine's verdict on one column for the side to move.
+type Hint struct {
+	Column int    `json:"column"`
+	Result string `json:"result"`          // "win", "draw", "loss" or "unknown"
+	Score  int    `json:"score"`           // solver score, higher is better
+	Plies  int    `json:"plies,omitempty"` // plies until the win or loss, counting this move
+}
+
+// HintResult scores every legal column of a position. Complete is false when
+// the time budget ran out before every column was solved; the unsolved ones
+// are reported as "unknown".
+type HintResult struct {
+	GameID   string `json:"game_id,omitempty"`
+	ToMove   Color  `json:"to_move"`
+	Best     int    `json:"best"`
+	Complete bool   `json:"complete"`
+	Hints    []Hint `json:"hints"`
+}
+
+// hint scores each legal move of pos, giving up on unsolved columns after
+// the bot time budget. If that leaves no good move proven, a search gets
+// another budget to suggest one.
+func (gs *GameServer) hint(pos *engine.Position) (*HintResult, error) {
+	if pos.IsOver() {
+		return nil, engine.ErrGameOver
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gs.botBudget)
+	defer cancel()
+	scores, err := bot.Analyze(ctx, pos)
+
+	res := &HintResult{ToMove: colorOf(pos.ToMove()), Best: -1, Complete: err == nil}
+	solved := make(map[int]int)
+	for _, ms := range scores {
+		solved[ms.Col] = ms.Score
+	}
+	for _, col := range pos.LegalMoves() {
+		h := Hint{Column: col, Result: "unknown"}
+		if score, ok := solved[col]; ok {
+			h.Score = score
+			h.Plies = engine.Plies(pos.Discs(), score)
+			switch {
+			case score > 0:
+				h.Result = "win"
+			case score < 0:
+				h.Result = "loss"
+			default:
+				h.Result = "draw"
+			}
+			if res.Best == -1 || score > solved[res.Best] {
+				res.Best = col
+			}
+		}
+		res.Hints = append(res.Hints, h)
+	}
+	if !res.Complete {
+		// Nothing worth playing was proven in time: suggest the search's
+		// choice instead, unless it is a proven loss
+		ctx, cancel := context.WithTimeout(context.Background(), gs.botBudget)
+		defer cancel()
+		col := bot.NewNegamax(gs.botDepth).ChooseMove(ctx, pos)
+		if _, ok := solved[col]; res.Best == -1 || !ok && solved[res.Best] < 0 {
+			res.Best = col
+		}
+	}
+	return res, nil
+}
+
+// hintPosition snapshots the position of a game for analysis. Hints are only
+// given in bot games and on imported boards, so nobody can consult the
+// engine against a human.
+func (gs *GameServer) hintPosition(gameID string) (*engine.Position, int, error) {
+	gs.mutex.RLock()
+	game := gs.games[gameID]
+	gs.mutex.RUnlock()
+	if game == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("game not found")
+	}
+
+	game.mutex.RLock()
+	defer game.mutex.RUnlock()
+	if game.Winner != "" {
+		return nil, http.StatusConflict, engine.ErrGameOver
+	}
+	if !game.IsBot && !game.Imported {
+		return nil, http.StatusForbidden, fmt.Errorf("hints are only available in bot games")
+	}
+	pos, err := positionFromBoard(game.Board, game.CurrentPlayer)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return pos, http.StatusOK, nil
+}
+
+// sendHint answers a "hint" message for the sender's current game. The
+// analysis runs without holding the game lock.
+func (gs *GameServer) sendHint(conn *Conn, game *GameState) {
+	pos, _, err := gs.hintPosition(game.ID)
+	if err == nil {
+		var res *HintResult
+		if res, err = gs.hint(pos); err == nil {
+			res.GameID = game.ID
+			conn.WriteJSON(Message{Type: "hint", GameID: game.ID, Hint: res})
+			return
+		}
+	}
+	conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+}
+
+// getHint serves GET /hint?game_id=... for a bot game in progress, or
+// GET /hint?moves=4453 for any position reached by a move list.
+func (gs *GameServer) getHint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !gs.allowFrom(gs.hintLimits, r, hintBurst, hintWindow, time.Now()) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": errTooManyHints.Error()})
+		return
+	}
+
+	gameID := r.URL.Query().Get("game_id")
+	var pos *engine.Position
+	var err error
+	status := http.StatusBadRequest
+	if gameID != "" {
+		pos, status, err = gs.hintPosition(gameID)
+	} else {
+		pos, err = engine.ParseMoves(r.URL.Query().Get("moves"))
+	}
+	if err != nil {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	res, err := gs.hint(pos)
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	res.GameID = gameID
+	log.Printf("💡 Hint: best col %d", res.Best)
+	json.NewEncoder(w).Encode(res)
+}
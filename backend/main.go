@@ -6,22 +6,49 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"connect4/bot"
+	"connect4/engine"
+	"connect4/matchmaking"
+	"connect4/rating"
+
 	"github.com/gorilla/websocket"
 	_ "github.com/lib/pq"
 	"github.com/segmentio/kafka-go"
 )
 
 const (
-	ROWS = 6
-	COLS = 7
+	ROWS = engine.Rows
+	COLS = engine.Cols
+
+	defaultBotDepth  = 7
+	defaultBotBudget = 2 * time.Second
 )
 
+// botMoveDelay keeps instant bot replies from feeling abrupt.
+var botMoveDelay = 500 * time.Millisecond
+
+// botLevel is the registered bot strategy behind a difficulty.
+type botLevel struct {
+	strategy string
+	depth    int // 0 uses the server's BOT_DEPTH
+}
+
+var botLevels = map[Difficulty]botLevel{
+	Easy:    {strategy: "random"},
+	Medium:  {strategy: "mcts"},
+	Hard:    {strategy: "negamax"},
+	Perfect: {strategy: "solver"},
+}
+
 type Color string
 
 const (
@@ -30,6 +57,39 @@ const (
 	Empty  Color = ""
 )
 
+type Difficulty string
+
+const (
+	Easy    Difficulty = "easy"
+	Medium  Difficulty = "medium"
+	Hard    Difficulty = "hard"
+	Perfect Difficulty = "perfect"
+)
+
+func parseDifficulty(s string) (Difficulty, bool) {
+	switch d := Difficulty(s); d {
+	case Easy, Medium, Hard, Perfect:
+		return d, true
+	case "":
+		return "", true // matched to the player's rating
+	}
+	return "", false
+}
+
+func (c Color) disc() engine.Disc {
+	switch c {
+	case Red:
+		return engine.Red
+	case Yellow:
+		return engine.Yellow
+	}
+	return engine.Empty
+}
+
+func colorOf(d engine.Disc) Color {
+	return Color(d.String())
+}
+
 type GameState struct {
 	ID            string
 	Board         [][]Color
@@ -37,104 +97,379 @@ type GameState struct {
 	Player2       *Player
 	CurrentPlayer Color
 	Winner        string
+	Termination   string // how the game ended, e.g. EndTimeout
 	StartTime     time.Time
 	EndTime       *time.Time
 	IsBot         bool
+	Rated         bool // counts towards both players' ratings
+	Difficulty    Difficulty
+	TimeControl   TimeControl
+	Clock         map[Color]time.Duration // time left when the current turn started
+	Bot           bot.Bot
+	Moves         []Move
+	Analysis      *GameAnalysis
+	Chat          []ChatLine
+	Imported      bool // an analysis board created from notation
+	analysing     bool // queued for analysis
+	Spectators    map[*Conn]bool
+	botCancel     context.CancelFunc
+	drawOffer     Color // the player whose draw offer stands
+	rematchOffer  Color // the player who asked for a rematch
+	undoRequest   Color // the player waiting for a take-back
+	turnStart     time.Time
+	flagTimer     *time.Timer
 	mutex         sync.RWMutex
 }
 
+// Move is one disc played in a game. Ply counts from 1.
+type Move struct {
+	Ply    int       `json:"ply"`
+	Column int       `json:"column"`
+	Row    int       `json:"row"`
+	Color  Color     `json:"color"`
+	Time   time.Time `json:"timestamp"`
+}
+
+// columns returns the columns played, in order.
+func columns(moves []Move) []int {
+	cols := make([]int, len(moves))
+	for i, m := range moves {
+		cols[i] = m.Column
+	}
+	return cols
+}
+
 type Player struct {
 	Username     string
 	Color        Color
-	Conn         *websocket.Conn
+	Conn         *Conn
 	LastSeen     time.Time
 	Disconnected bool
+	Difficulty   Difficulty
+	TimeControl  TimeControl
+	Rating       rating.Rating
 }
 
 type Message struct {
-	Type          string    `json:"type"`
-	Username      string    `json:"username,omitempty"`
-	Column        int       `json:"column,omitempty"`
-	Board         [][]Color `json:"board,omitempty"`
-	CurrentPlayer Color     `json:"current_player,omitempty"`
-	Color         Color     `json:"color,omitempty"`
-	Opponent      string    `json:"opponent,omitempty"`
-	Winner        string    `json:"winner,omitempty"`
-	GameID        string    `json:"game_id,omitempty"`
-	Message       string    `json:"message,omitempty"`
+	Type           string          `json:"type"`
+	Username       string          `json:"username,omitempty"`
+	Column         int             `json:"column,omitempty"`
+	Board          [][]Color       `json:"board,omitempty"`
+	CurrentPlayer  Color           `json:"current_player,omitempty"`
+	Color          Color           `json:"color,omitempty"`
+	Opponent       string          `json:"opponent,omitempty"`
+	Winner         string          `json:"winner,omitempty"`
+	WinLine        []engine.Cell   `json:"win_line,omitempty"`
+	GameID         string          `json:"game_id,omitempty"`
+	Message        string          `json:"message,omitempty"`
+	Difficulty     Difficulty      `json:"difficulty,omitempty"`
+	Hint           *HintResult     `json:"hint,omitempty"`
+	Analysis       *GameAnalysis   `json:"analysis,omitempty"`
+	Ply            int             `json:"ply,omitempty"`
+	Speed          float64         `json:"speed,omitempty"`
+	Game           *GameRecord     `json:"game,omitempty"`
+	Spectators     int             `json:"spectators,omitempty"`
+	RoomCode       string          `json:"room_code,omitempty"`
+	ChallengeID    string          `json:"challenge_id,omitempty"`
+	OpponentRating float64         `json:"opponent_rating,omitempty"`
+	Rated          bool            `json:"rated,omitempty"`
+	TimeControl    string          `json:"time_control,omitempty"`
+	Clock          map[Color]int64 `json:"clock,omitempty"` // milliseconds left
+	Termination    string          `json:"termination,omitempty"`
+	Emote          Emote           `json:"emote,omitempty"`
 }
 
 type GameServer struct {
-	games          map[string]*GameState
-	playerGames    map[string]*GameState  // Track which game each player is in
-	waitingPlayers []*Player
-	upgrader       websocket.Upgrader
-	mutex          sync.RWMutex
-	db             *sql.DB
-	kafkaWriter    *kafka.Writer
+	games            map[string]*GameState
+	playerGames      map[string]*GameState // Track which game each player is in
+	queue            *matchmaking.Queue
+	waiting          map[string]*Player // Queued players by username
+	rooms            map[string]*Room
+	online           map[string]*Conn // Presence: who is connected, by username
+	challenges       map[string]*Challenge
+	importLimits     map[string]*rateLimiter // Imports by client address
+	hintLimits       map[string]*rateLimiter // Hint requests by client address
+	analyses         chan analysisJob
+	upgrader         websocket.Upgrader
+	mutex            sync.RWMutex
+	db               *sql.DB
+	kafkaWriter      *kafka.Writer
+	botDepth         int
+	botBudget        time.Duration
+	analysisBudget   time.Duration
+	roomTimeout      time.Duration
+	challengeTimeout time.Duration
+	importTTL        time.Duration
+	chatFilter       wordFilter
+	moderatorToken   string
 }
 
+// LeaderboardEntry ranks a rated player. Wins count every game, bot games
+// included; only human games move the rating.
 type LeaderboardEntry struct {
-	Username string `json:"username"`
-	Wins     int    `json:"wins"`
+	Username  string  `json:"username"`
+	Rating    float64 `json:"rating"`
+	Deviation float64 `json:"deviation"`
+	Games     int     `json:"rated_games"`
+	Wins      int     `json:"wins"`
 }
 
 func NewGameServer(db *sql.DB, kafkaWriter *kafka.Writer) *GameServer {
 	return &GameServer{
-		games:       make(map[string]*GameState),
-		playerGames: make(map[string]*GameState),
-		upgrader:    websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
-		db:          db,
-		kafkaWriter: kafkaWriter,
+		games:            make(map[string]*GameState),
+		playerGames:      make(map[string]*GameState),
+		queue:            matchmaking.NewQueue(matchmaking.DefaultConfig, nil),
+		waiting:          make(map[string]*Player),
+		rooms:            make(map[string]*Room),
+		online:           make(map[string]*Conn),
+		challenges:       make(map[string]*Challenge),
+		importLimits:     make(map[string]*rateLimiter),
+		hintLimits:       make(map[string]*rateLimiter),
+		analyses:         make(chan analysisJob, analysisQueueSize),
+		upgrader:         websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		db:               db,
+		kafkaWriter:      kafkaWriter,
+		botDepth:         defaultBotDepth,
+		botBudget:        defaultBotBudget,
+		analysisBudget:   defaultAnalysisBudget,
+		roomTimeout:      defaultRoomTimeout,
+		challengeTimeout: defaultChallengeTimeout,
+		importTTL:        defaultImportTTL,
 	}
 }
 
 func (gs *GameServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := gs.upgrader.Upgrade(w, r, nil)
+	ws, err := gs.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("❌ Upgrade error:", err)
 		return
 	}
-	defer conn.Close()
+	defer ws.Close()
+	conn := newConn(ws)
 
 	log.Println("✓ New WebSocket connection")
 
 	var player *Player
 	var game *GameState
+	var rp *replay
+	var watching *GameState
+	var room *Room
+	var username string
+	chatLimit := rateLimiter{burst: chatBurst, window: chatWindow}
+	defer func() {
+		if username != "" {
+			gs.setOffline(username, conn)
+		}
+		if player != nil {
+			gs.dequeue(player)
+		}
+		if room != nil {
+			gs.closeRoom(room)
+		}
+		if rp != nil {
+			rp.stop()
+		}
+		if watching != nil {
+			gs.unspectate(conn, watching)
+		}
+	}()
 
 	for {
 		var msg Message
 		err := conn.ReadJSON(&msg)
 		if err != nil {
-			if player != nil && game != nil {
-				gs.handleDisconnect(player, game)
+			if player != nil {
+				if current := gs.currentGame(game, player.Username); current != nil {
+					gs.handleDisconnect(player, current)
+				}
 			}
 			break
 		}
 
 		log.Printf("📨 %s from %s", msg.Type, msg.Username)
 
+		if msg.Username != "" && msg.Username != username {
+			if username != "" {
+				gs.setOffline(username, conn)
+			}
+			username = msg.Username
+			gs.setOnline(username, conn)
+		}
+
 		switch msg.Type {
 		case "join":
-			player = &Player{Username: msg.Username, Conn: conn, LastSeen: time.Now()}
-			log.Printf("👤 %s joining", player.Username)
+			difficulty, ok := parseDifficulty(string(msg.Difficulty))
+			if !ok {
+				conn.WriteJSON(Message{Type: "error", Message: "Unknown difficulty"})
+				continue
+			}
+			tc, err := parseTimeControl(msg.TimeControl)
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+				continue
+			}
+			player = &Player{Username: msg.Username, Conn: conn, LastSeen: time.Now(), Difficulty: difficulty, TimeControl: tc, Rating: gs.ratingOf(msg.Username)}
+			log.Printf("👤 %s joining (bot difficulty: %s)", player.Username, difficulty)
 			game = gs.matchPlayer(player)
+		case "create_room":
+			tc, err := parseTimeControl(msg.TimeControl)
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+				continue
+			}
+			if room != nil {
+				gs.closeRoom(room)
+			}
+			player = &Player{Username: msg.Username, Conn: conn, LastSeen: time.Now(), TimeControl: tc, Rating: gs.ratingOf(msg.Username)}
+			room = gs.createRoom(player)
+		case "join_room":
+			guest := &Player{Username: msg.Username, Conn: conn, LastSeen: time.Now(), Rating: gs.ratingOf(msg.Username)}
+			joined, err := gs.joinRoom(guest, strings.ToUpper(strings.TrimSpace(msg.RoomCode)))
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+				continue
+			}
+			player, game = guest, joined
 		case "move":
-			// Look up the game for this player
-			gs.mutex.RLock()
-			game = gs.playerGames[player.Username]
-			gs.mutex.RUnlock()
+			if player == nil {
+				if watching != nil {
+					conn.WriteJSON(Message{Type: "error", Message: "Spectators cannot move"})
+					continue
+				}
+				log.Println("❌ Move received but player is nil")
+				conn.WriteJSON(Message{Type: "error", Message: "Player not found"})
+				continue
+			}
+
+			// Look up the game for this player, unless the move names the
+			// correspondence game this connection resumed
+			if msg.GameID == "" || game == nil || game.ID != msg.GameID {
+				gs.mutex.RLock()
+				game = gs.playerGames[player.Username]
+				gs.mutex.RUnlock()
+			}
 			
 			if game == nil {
 				log.Printf("❌ Move received but no game found for %s", player.Username)
 				conn.WriteJSON(Message{Type: "error", Message: "Game not found"})
-			} else if player == nil {
-				log.Println("❌ Move received but player is nil")
-				conn.WriteJSON(Message{Type: "error", Message: "Player not found"})
 			} else {
 				log.Printf("🎮 %s → column %d", player.Username, msg.Column)
 				gs.handleMove(game, player, msg.Column)
 			}
+		case "resign", "offer_draw", "accept_draw", "decline_draw", "rematch", "undo_request", "undo_accept", "undo_decline":
+			if player == nil {
+				conn.WriteJSON(Message{Type: "error", Message: "Player not found"})
+				continue
+			}
+			if game = gs.currentGame(game, player.Username); game == nil {
+				conn.WriteJSON(Message{Type: "error", Message: "Game not found"})
+				continue
+			}
+			var err error
+			switch msg.Type {
+			case "resign":
+				err = gs.resign(game, player.Username)
+			case "offer_draw":
+				err = gs.offerDraw(game, player.Username)
+			case "accept_draw", "decline_draw":
+				err = gs.answerDraw(game, player.Username, msg.Type == "accept_draw")
+			case "undo_request":
+				err = gs.requestUndo(game, player.Username)
+			case "undo_accept", "undo_decline":
+				err = gs.answerUndo(game, player.Username, msg.Type == "undo_accept")
+			case "rematch":
+				var next *GameState
+				if next, err = gs.rematch(game, player.Username); next != nil {
+					game = next
+				}
+			}
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+			}
+		case "chat":
+			if player == nil {
+				conn.WriteJSON(Message{Type: "error", Message: "Player not found"})
+				continue
+			}
+			if game = gs.currentGame(game, player.Username); game == nil {
+				conn.WriteJSON(Message{Type: "error", Message: "Game not found"})
+				continue
+			}
+			if !chatLimit.allow(time.Now()) {
+				conn.WriteJSON(Message{Type: "error", Message: errChatTooFast.Error()})
+				continue
+			}
+			if err := gs.chat(game, player.Username, msg.Message, msg.Emote); err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+			}
+		case "resume":
+			resumed, seat, err := gs.resumeGame(conn, username, msg.GameID)
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+				continue
+			}
+			player, game = seat, resumed
+		case "hint":
+			if player == nil {
+				conn.WriteJSON(Message{Type: "error", Message: "Player not found"})
+				continue
+			}
+			gs.mutex.RLock()
+			game = gs.playerGames[player.Username]
+			gs.mutex.RUnlock()
+			if game == nil {
+				conn.WriteJSON(Message{Type: "error", Message: "Game not found"})
+				continue
+			}
+			gs.sendHint(conn, game)
+		case "challenge":
+			tc, err := parseTimeControl(msg.TimeControl)
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+				continue
+			}
+			player = &Player{Username: msg.Username, Conn: conn, LastSeen: time.Now(), TimeControl: tc, Rating: gs.ratingOf(msg.Username)}
+			if _, err := gs.challenge(player, msg.Opponent); err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+			}
+		case "accept":
+			challenged := &Player{Username: username, Conn: conn, LastSeen: time.Now(), Rating: gs.ratingOf(username)}
+			accepted, err := gs.acceptChallenge(challenged, msg.ChallengeID)
+			if err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+				continue
+			}
+			player, game = challenged, accepted
+		case "decline":
+			if err := gs.declineChallenge(username, msg.ChallengeID); err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+			}
+		case "spectate":
+			if watching != nil {
+				gs.unspectate(conn, watching)
+			}
+			var err error
+			if watching, err = gs.spectate(conn, msg.GameID); err != nil {
+				conn.WriteJSON(Message{Type: "error", Message: err.Error()})
+			}
+		case "unspectate":
+			if watching != nil {
+				gs.unspectate(conn, watching)
+				watching = nil
+			}
+		case "replay":
+			if rp != nil {
+				rp.stop()
+			}
+			var err error
+			if rp, err = gs.startReplay(conn, msg.GameID, msg.Speed); err != nil {
+				rp = nil
+				conn.WriteJSON(Message{Type: "error", Message: "Game not found"})
+			}
+		case "replay_pause", "replay_resume", "replay_seek", "replay_speed", "replay_stop":
+			if rp == nil || !rp.control(msg) {
+				conn.WriteJSON(Message{Type: "error", Message: "No replay running"})
+			}
 		}
 	}
 }
@@ -143,14 +478,15 @@ func (gs *GameServer) matchPlayer(player *Player) *GameState {
 	gs.mutex.Lock()
 	defer gs.mutex.Unlock()
 
-	// Check rejoin
+	// Check rejoin. Correspondence games are resumed by id instead.
 	for _, game := range gs.games {
-		if game.Winner == "" {
+		if game.Winner == "" && !game.TimeControl.Correspondence {
 			if game.Player1.Username == player.Username && game.Player1.Disconnected {
 				game.Player1.Conn = player.Conn
 				game.Player1.Disconnected = false
 				player.Color = game.Player1.Color
 				gs.sendGameState(game)
+				gs.resumeBotTurn(game)
 				log.Printf("🔄 %s reconnected", player.Username)
 				return game
 			}
@@ -159,46 +495,21 @@ func (gs *GameServer) matchPlayer(player *Player) *GameState {
 				game.Player2.Disconnected = false
 				player.Color = game.Player2.Color
 				gs.sendGameState(game)
+				gs.resumeBotTurn(game)
 				log.Printf("🔄 %s reconnected", player.Username)
 				return game
 			}
 		}
 	}
 
-	// Match with waiting player
-	if len(gs.waitingPlayers) > 0 {
-		opponent := gs.waitingPlayers[0]
-		gs.waitingPlayers = gs.waitingPlayers[1:]
-		log.Printf("👥 Matching %s vs %s", opponent.Username, player.Username)
-		return gs.createGame(opponent, player, false)
-	}
-
-	// Add to waiting list
-	gs.waitingPlayers = append(gs.waitingPlayers, player)
-	player.Conn.WriteJSON(Message{Type: "waiting"})
-	log.Printf("⏳ %s waiting", player.Username)
-
-	// Bot timer
-	go func() {
-		time.Sleep(10 * time.Second)
-		gs.mutex.Lock()
-		defer gs.mutex.Unlock()
-		for i, p := range gs.waitingPlayers {
-			if p == player {
-				gs.waitingPlayers = append(gs.waitingPlayers[:i], gs.waitingPlayers[i+1:]...)
-				bot := &Player{Username: "Bot", Color: Yellow}
-				log.Printf("🤖 Bot joining %s", player.Username)
-				gs.createGame(player, bot, true)
-				break
-			}
-		}
-	}()
-
-	return nil
+	return gs.enqueue(player)
 }
 
-func (gs *GameServer) createGame(p1, p2 *Player, isBot bool) *GameState {
+// createGame starts a game with p1 as red, on p1's time control. Bot games
+// are never rated.
+func (gs *GameServer) createGame(p1, p2 *Player, b bot.Bot, rated bool) *GameState {
 	gameID := generateID()
+	isBot := b != nil
 	p1.Color = Red
 	p2.Color = Yellow
 
@@ -209,7 +520,16 @@ func (gs *GameServer) createGame(p1, p2 *Player, isBot bool) *GameState {
 
 	game := &GameState{
 		ID: gameID, Board: board, Player1: p1, Player2: p2,
-		CurrentPlayer: Red, StartTime: time.Now(), IsBot: isBot,
+		CurrentPlayer: Red, StartTime: time.Now(), IsBot: isBot, Rated: rated && !isBot,
+		TimeControl: p1.TimeControl,
+	}
+	if isBot {
+		game.Difficulty = p1.Difficulty
+		game.Bot = b
+	}
+	gs.startClock(game)
+	if game.TimeControl.Correspondence {
+		gs.saveGame(game)
 	}
 	gs.games[gameID] = game
 	
@@ -222,14 +542,21 @@ func (gs *GameServer) createGame(p1, p2 *Player, isBot bool) *GameState {
 	log.Printf("🎮 Game %s: %s vs %s", gameID, p1.Username, p2.Username)
 
 	if p1.Conn != nil {
-		p1.Conn.WriteJSON(Message{Type: "game_start", Color: Red, Opponent: p2.Username, CurrentPlayer: Red, GameID: gameID})
+		msg := Message{Type: "game_start", Color: Red, Opponent: p2.Username, CurrentPlayer: Red, GameID: gameID, Difficulty: game.Difficulty, Rated: game.Rated,
+			TimeControl: game.TimeControl.String(), Clock: clocks(game)}
+		if !isBot {
+			msg.OpponentRating = math.Round(p2.Rating.Rating)
+		}
+		p1.Conn.WriteJSON(msg)
 	}
 	if !isBot && p2.Conn != nil {
-		p2.Conn.WriteJSON(Message{Type: "game_start", Color: Yellow, Opponent: p1.Username, CurrentPlayer: Red, GameID: gameID})
+		p2.Conn.WriteJSON(Message{Type: "game_start", Color: Yellow, Opponent: p1.Username, OpponentRating: math.Round(p1.Rating.Rating), CurrentPlayer: Red, GameID: gameID, Rated: game.Rated,
+			TimeControl: game.TimeControl.String(), Clock: clocks(game)})
 	}
 
 	gs.sendKafkaEvent("game_start", map[string]interface{}{
-		"game_id": gameID, "player1": p1.Username, "player2": p2.Username, "is_bot": isBot,
+		"game_id": gameID, "player1": p1.Username, "player2": p2.Username, "is_bot": isBot, "rated": game.Rated, "difficulty": game.Difficulty,
+		"bot": botName(game), "time_control": game.TimeControl.String(),
 	})
 
 	return game
@@ -239,6 +566,12 @@ func (gs *GameServer) handleMove(game *GameState, player *Player, col int) {
 	game.mutex.Lock()
 	defer game.mutex.Unlock()
 
+	// A player who rejoined has a copy of their seat, whose colour a
+	// rematch may since have swapped
+	if seat := seatOf(game, player.Username); seat != nil {
+		player = seat
+	}
+
 	log.Printf("🎯 %s (%s) → col %d (turn: %s)", player.Username, player.Color, col, game.CurrentPlayer)
 
 	if game.Winner != "" {
@@ -254,228 +587,220 @@ func (gs *GameServer) handleMove(game *GameState, player *Player, col int) {
 		return
 	}
 
-	if col < 0 || col >= COLS {
-		log.Printf("❌ Invalid column")
-		return
-	}
-
-	row := -1
-	for r := ROWS - 1; r >= 0; r-- {
-		if game.Board[r][col] == Empty {
-			row = r
-			break
-		}
-	}
-
-	if row == -1 {
-		log.Printf("❌ Column full")
-		if player.Conn != nil {
+	row, err := gs.playDisc(game, player.Color, col)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		if err == engine.ErrColumnFull && player.Conn != nil {
 			player.Conn.WriteJSON(Message{Type: "error", Message: "Column is full"})
 		}
 		return
 	}
-
-	game.Board[row][col] = player.Color
 	log.Printf("✓ Placed at [%d,%d]", row, col)
 
-	if gs.checkWinner(game, row, col) {
-		game.Winner = string(player.Color)
-		endTime := time.Now()
-		game.EndTime = &endTime
-		log.Printf("🏆 Winner: %s", game.Winner)
-		gs.saveGame(game)
-		gs.broadcastGameOver(game)
-		return
-	}
-
-	if gs.isBoardFull(game) {
-		game.Winner = "draw"
-		endTime := time.Now()
-		game.EndTime = &endTime
-		log.Println("🤝 Draw")
-		gs.saveGame(game)
-		gs.broadcastGameOver(game)
+	if game.Winner != "" {
 		return
 	}
 
-	if game.CurrentPlayer == Red {
-		game.CurrentPlayer = Yellow
-	} else {
-		game.CurrentPlayer = Red
-	}
-
 	log.Printf("🔄 Turn: %s", game.CurrentPlayer)
 	gs.broadcastMove(game)
 
 	if game.IsBot && game.CurrentPlayer == Yellow {
-		log.Println("🤖 Bot thinking...")
-		go func() {
-			time.Sleep(500 * time.Millisecond)
-			gs.makeBotMove(game)
-		}()
+		gs.startBotTurn(game)
+	}
+}
+
+// startBotTurn lets the bot think about a copy of the position without
+// holding the game lock, so other messages for the game are not blocked.
+// The caller holds game.mutex.
+func (gs *GameServer) startBotTurn(game *GameState) {
+	pos, err := positionFromBoard(game.Board, Yellow)
+	if err != nil {
+		log.Printf("❌ Bad board for bot: %v", err)
+		return
 	}
+	b := gs.botFor(game)
+	gs.cancelBotTurn(game)
+	ctx, cancel := context.WithCancel(context.Background())
+	game.botCancel = cancel
+
+	log.Println("🤖 Bot thinking...")
+	go func() {
+		defer cancel()
+		select {
+		case <-time.After(botMoveDelay):
+		case <-ctx.Done():
+			return
+		}
+		thinkCtx, stop := context.WithTimeout(ctx, gs.botBudget)
+		col := gs.chooseBotMove(thinkCtx, b, pos)
+		stop()
+		gs.makeBotMove(ctx, game, pos.Cells(), col)
+	}()
 }
 
-func (gs *GameServer) makeBotMove(game *GameState) {
+// resumeBotTurn restarts the bot's turn if it was cancelled by a disconnect.
+func (gs *GameServer) resumeBotTurn(game *GameState) {
 	game.mutex.Lock()
 	defer game.mutex.Unlock()
+	if game.IsBot && game.CurrentPlayer == Yellow && game.Winner == "" {
+		gs.startBotTurn(game)
+	}
+}
 
-	if game.Winner != "" {
-		return
+// cancelBotTurn stops any bot thinking for the game. The caller holds
+// game.mutex.
+func (gs *GameServer) cancelBotTurn(game *GameState) {
+	if game.botCancel != nil {
+		game.botCancel()
+		game.botCancel = nil
 	}
+}
 
-	col := gs.getBotMove(game)
-	if col == -1 {
+// makeBotMove applies the bot's choice, unless the turn was cancelled or the
+// board changed while the bot was thinking.
+func (gs *GameServer) makeBotMove(ctx context.Context, game *GameState, before engine.Grid, col int) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+
+	if ctx.Err() != nil {
+		log.Println("🤖 Bot turn cancelled")
 		return
 	}
-
-	row := -1
-	for r := ROWS - 1; r >= 0; r-- {
-		if game.Board[r][col] == Empty {
-			row = r
-			break
-		}
+	game.botCancel = nil
+	if game.Winner != "" || game.CurrentPlayer != Yellow || gridFromBoard(game.Board) != before {
+		log.Println("🤖 Position changed while thinking - move discarded")
+		return
 	}
-
-	if row == -1 {
+	if col == -1 {
 		return
 	}
 
-	game.Board[row][col] = Yellow
-	log.Printf("🤖 Bot → [%d,%d]", row, col)
-
-	if gs.checkWinner(game, row, col) {
-		game.Winner = "yellow"
-		endTime := time.Now()
-		game.EndTime = &endTime
-		log.Println("🤖 Bot wins!")
-		gs.saveGame(game)
-		gs.broadcastGameOver(game)
+	row, err := gs.playDisc(game, Yellow, col)
+	if err != nil {
+		log.Printf("❌ Bot move rejected: %v", err)
 		return
 	}
+	log.Printf("🤖 Bot → [%d,%d]", row, col)
 
-	if gs.isBoardFull(game) {
-		game.Winner = "draw"
-		endTime := time.Now()
-		game.EndTime = &endTime
-		gs.saveGame(game)
-		gs.broadcastGameOver(game)
+	if game.Winner != "" {
 		return
 	}
 
-	game.CurrentPlayer = Red
 	gs.broadcastMove(game)
 }
 
-func (gs *GameServer) getBotMove(game *GameState) int {
-	for col := 0; col < COLS; col++ {
-		if gs.canWin(game, col, Yellow) {
-			return col
-		}
+// playDisc drops a disc for color in col, updates the board and either ends
+// the game, if the move wins or fills the board, or passes the turn. The
+// caller holds game.mutex.
+func (gs *GameServer) playDisc(game *GameState, color Color, col int) (int, error) {
+	pos, err := positionFromBoard(game.Board, color)
+	if err != nil {
+		return -1, err
 	}
-	for col := 0; col < COLS; col++ {
-		if gs.canWin(game, col, Red) {
-			return col
-		}
+	row, err := pos.Play(col)
+	if err != nil {
+		return -1, err
 	}
-	if gs.isColumnAvailable(game, 3) {
-		return 3
+	if !gs.pressClock(game, color) {
+		return -1, errTimeUp
 	}
-	for _, col := range []int{2, 4, 1, 5, 0, 6} {
-		if gs.isColumnAvailable(game, col) {
-			return col
+	game.Board[row][col] = color
+	if game.drawOffer != color {
+		game.drawOffer = "" // moving declines the opponent's offer
+	}
+	game.undoRequest = ""
+	game.Moves = append(game.Moves, Move{Ply: len(game.Moves) + 1, Column: col, Row: row, Color: color, Time: time.Now()})
+
+	if winner := pos.Winner(); winner != engine.Empty {
+		log.Printf("🎉 Four in a row for %s: %v", color, pos.WinLine())
+		gs.endGame(game, string(colorOf(winner)), EndNormal, pos.WinLine())
+	} else if pos.IsFull() {
+		log.Println("🤝 Draw")
+		gs.endGame(game, "draw", EndNormal, nil)
+	} else {
+		game.CurrentPlayer = colorOf(pos.ToMove())
+		gs.startTurnClock(game, game.CurrentPlayer)
+		if game.TimeControl.Correspondence {
+			gs.saveGame(game)
 		}
 	}
-	return -1
+	return row, nil
 }
 
-func (gs *GameServer) canWin(game *GameState, col int, color Color) bool {
-	row := -1
-	for r := ROWS - 1; r >= 0; r-- {
-		if game.Board[r][col] == Empty {
-			row = r
-			break
-		}
+func (gs *GameServer) endGame(game *GameState, winner, termination string, winLine []engine.Cell) {
+	gs.cancelBotTurn(game)
+	gs.stopClock(game)
+	game.Winner = winner
+	game.Termination = termination
+	endTime := time.Now()
+	game.EndTime = &endTime
+	log.Printf("🏆 Winner: %s", game.Winner)
+	gs.saveGame(game)
+	gs.broadcastGameOver(game, winLine)
+}
+
+func (gs *GameServer) chooseBotMove(ctx context.Context, b bot.Bot, pos *engine.Position) int {
+	start := time.Now()
+	col := b.ChooseMove(ctx, pos)
+	log.Printf("🤖 %s → col %d (%v)", b.Name(), col, time.Since(start).Round(time.Millisecond))
+	return col
+}
+
+func (gs *GameServer) botFor(game *GameState) bot.Bot {
+	if game.Bot == nil {
+		game.Bot = gs.newBot(game.Difficulty)
+	}
+	return game.Bot
+}
+
+// newBot builds the strategy for a difficulty, falling back to the default
+// search if the level is unknown or misconfigured.
+func (gs *GameServer) newBot(d Difficulty) bot.Bot {
+	level, ok := botLevels[d]
+	if !ok {
+		level = botLevels[Hard]
 	}
-	if row == -1 {
-		return false
+	depth := level.depth
+	if depth == 0 {
+		depth = gs.botDepth
 	}
-	game.Board[row][col] = color
-	wins := gs.checkWinner(game, row, col)
-	game.Board[row][col] = Empty
-	return wins
+	b, err := bot.New(level.strategy, bot.Options{Depth: depth, Budget: gs.botBudget})
+	if err != nil {
+		log.Printf("⚠ %v - using default bot", err)
+		return bot.NewNegamax(depth)
+	}
+	return b
 }
 
-func (gs *GameServer) isColumnAvailable(game *GameState, col int) bool {
-	return col >= 0 && col < COLS && game.Board[0][col] == Empty
+func botUsername(b bot.Bot) string {
+	return fmt.Sprintf("Bot (%s)", b.Name())
 }
 
-func (gs *GameServer) checkWinner(game *GameState, row, col int) bool {
-	color := game.Board[row][col]
-	if color == Empty {
-		return false
-	}
-	
-	// Check all 4 directions: horizontal, vertical, diagonal-right, diagonal-left
-	directions := [][2]int{
-		{0, 1},  // horizontal
-		{1, 0},  // vertical
-		{1, 1},  // diagonal down-right
-		{1, -1}, // diagonal down-left
-	}
-
-	for _, dir := range directions {
-		count := 1 // Count the current disc
-		
-		// Check positive direction
-		for i := 1; i < 4; i++ {
-			r := row + dir[0]*i
-			c := col + dir[1]*i
-			if r < 0 || r >= ROWS || c < 0 || c >= COLS {
-				break
-			}
-			if game.Board[r][c] != color {
-				break
-			}
-			count++
-		}
-		
-		// Check negative direction
-		for i := 1; i < 4; i++ {
-			r := row - dir[0]*i
-			c := col - dir[1]*i
-			if r < 0 || r >= ROWS || c < 0 || c >= COLS {
-				break
-			}
-			if game.Board[r][c] != color {
-				break
-			}
-			count++
-		}
-		
-		// If we found 4 or more in a row, we have a winner!
-		if count >= 4 {
-			log.Printf("🎉 Found %d in a row for %s! (direction: %v)", count, color, dir)
-			return true
-		}
+func botName(game *GameState) string {
+	if game.Bot == nil {
+		return ""
 	}
-	
-	return false
+	return game.Bot.Name()
 }
 
-func (gs *GameServer) isBoardFull(game *GameState) bool {
-	for _, row := range game.Board {
-		for _, cell := range row {
-			if cell == Empty {
-				return false
-			}
+func gridFromBoard(board [][]Color) engine.Grid {
+	var grid engine.Grid
+	for r := range board {
+		for c := range board[r] {
+			grid[r][c] = board[r][c].disc()
 		}
 	}
-	return true
+	return grid
+}
+
+// positionFromBoard converts the wire board into an engine position with
+// toMove to play.
+func positionFromBoard(board [][]Color, toMove Color) (*engine.Position, error) {
+	return engine.FromCells(gridFromBoard(board), toMove.disc())
 }
 
 func (gs *GameServer) broadcastMove(game *GameState) {
-	msg := Message{Type: "move", Board: game.Board, CurrentPlayer: game.CurrentPlayer}
+	msg := Message{Type: "move", Board: game.Board, CurrentPlayer: game.CurrentPlayer, Spectators: len(game.Spectators), Clock: clocks(game)}
 	
 	log.Printf("📤 Broadcasting move - Current player: %s", game.CurrentPlayer)
 	
@@ -494,10 +819,11 @@ func (gs *GameServer) broadcastMove(game *GameState) {
 			log.Printf("✓ Sent to %s", game.Player2.Username)
 		}
 	}
+	gs.sendSpectators(game, msg)
 }
 
-func (gs *GameServer) broadcastGameOver(game *GameState) {
-	msg := Message{Type: "game_over", Board: game.Board, Winner: game.Winner}
+func (gs *GameServer) broadcastGameOver(game *GameState, winLine []engine.Cell) {
+	msg := Message{Type: "game_over", Board: game.Board, Winner: game.Winner, WinLine: winLine, Termination: game.Termination, Clock: clocks(game)}
 	
 	log.Printf("🏁 Broadcasting game over - Winner: %s", game.Winner)
 	log.Printf("   Player1: %s (%s)", game.Player1.Username, game.Player1.Color)
@@ -520,16 +846,33 @@ func (gs *GameServer) broadcastGameOver(game *GameState) {
 			log.Printf("✓ Sent game over to %s", game.Player2.Username)
 		}
 	}
+	gs.sendSpectators(game, msg)
 	
 	gs.sendKafkaEvent("game_end", map[string]interface{}{
-		"game_id": game.ID, "winner": game.Winner, "duration": time.Since(game.StartTime).Seconds(), "is_bot": game.IsBot,
+		"game_id": game.ID, "winner": game.Winner, "termination": game.Termination, "duration": time.Since(game.StartTime).Seconds(), "is_bot": game.IsBot,
+		"difficulty": game.Difficulty, "bot": botName(game),
 	})
+	gs.startAnalysis(game)
 }
 
+// handleDisconnect marks player's seat in game as disconnected and forfeits
+// the game if they are not back within 30 seconds. The seat is found by
+// username, and is left alone if it has moved to a newer connection.
 func (gs *GameServer) handleDisconnect(player *Player, game *GameState) {
 	game.mutex.Lock()
 	defer game.mutex.Unlock()
+	seat := seatOf(game, player.Username)
+	if seat == nil || seat.Conn != player.Conn {
+		return
+	}
+	player = seat
 	player.Disconnected = true
+	if game.TimeControl.Correspondence {
+		// Correspondence players come and go; only the clock can end the game
+		player.Conn = nil
+		return
+	}
+	gs.cancelBotTurn(game)
 	opponent := gs.getOpponent(game, player)
 	if opponent != nil && opponent.Conn != nil {
 		opponent.Conn.WriteJSON(Message{Type: "opponent_disconnected"})
@@ -539,13 +882,19 @@ func (gs *GameServer) handleDisconnect(player *Player, game *GameState) {
 		game.mutex.Lock()
 		defer game.mutex.Unlock()
 		if player.Disconnected && game.Winner == "" {
+			gs.cancelBotTurn(game)
+			gs.stopClock(game)
 			game.Winner = string(gs.getOpponent(game, player).Color)
+			game.Termination = EndForfeit
 			endTime := time.Now()
 			game.EndTime = &endTime
 			gs.saveGame(game)
+			msg := Message{Type: "game_forfeited", Winner: game.Winner, Termination: game.Termination}
 			if opp := gs.getOpponent(game, player); opp != nil && opp.Conn != nil {
-				opp.Conn.WriteJSON(Message{Type: "game_forfeited", Winner: game.Winner})
+				opp.Conn.WriteJSON(msg)
 			}
+			gs.sendSpectators(game, msg)
+			gs.startAnalysis(game)
 		}
 	}()
 }
@@ -558,7 +907,7 @@ func (gs *GameServer) getOpponent(game *GameState, player *Player) *Player {
 }
 
 func (gs *GameServer) sendGameState(game *GameState) {
-	msg := Message{Type: "move", Board: game.Board, CurrentPlayer: game.CurrentPlayer}
+	msg := Message{Type: "move", Board: game.Board, CurrentPlayer: game.CurrentPlayer, Clock: clocks(game)}
 	if game.Player1.Conn != nil && !game.Player1.Disconnected {
 		game.Player1.Conn.WriteJSON(msg)
 	}
@@ -567,6 +916,8 @@ func (gs *GameServer) sendGameState(game *GameState) {
 	}
 }
 
+// saveGame writes a finished game, or the progress of a correspondence game
+// so it can be restored. The caller holds game.mutex.
 func (gs *GameServer) saveGame(game *GameState) {
 	if gs.db == nil {
 		log.Println("⚠ Database not available - game not saved")
@@ -586,15 +937,56 @@ func (gs *GameServer) saveGame(game *GameState) {
 		winnerUsername = game.Player2.Username
 	}
 	
-	_, err := gs.db.Exec(`
-		INSERT INTO games (id, player1, player2, winner, start_time, end_time, is_bot) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, game.ID, game.Player1.Username, game.Player2.Username, winnerUsername, game.StartTime, game.EndTime, game.IsBot)
-	
+	// The game row and its moves are written together or not at all
+	tx, err := gs.db.Begin()
+	if err != nil {
+		log.Println("❌ Error saving game:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO games (id, player1, player2, winner, start_time, end_time, is_bot, difficulty, rated, time_control, termination, deadline) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET
+			winner = EXCLUDED.winner, end_time = EXCLUDED.end_time,
+			termination = EXCLUDED.termination, deadline = EXCLUDED.deadline
+	`, game.ID, game.Player1.Username, game.Player2.Username, winnerUsername, game.StartTime, game.EndTime, game.IsBot, game.Difficulty, game.Rated,
+		game.TimeControl.String(), game.Termination, deadline(game))
 	if err != nil {
 		log.Println("❌ Error saving game:", err)
+		return
+	}
+	// A take-back may have replaced moves saved earlier in a correspondence game
+	if _, err = tx.Exec(`DELETE FROM moves WHERE game_id = $1 AND ply > $2`, game.ID, len(game.Moves)); err != nil {
+		log.Println("❌ Error saving moves:", err)
+		return
+	}
+	for _, m := range game.Moves {
+		_, err = tx.Exec(`
+			INSERT INTO moves (game_id, ply, col, row, color, played_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (game_id, ply) DO UPDATE SET
+				col = EXCLUDED.col, row = EXCLUDED.row, color = EXCLUDED.color, played_at = EXCLUDED.played_at
+		`, game.ID, m.Ply, m.Column, m.Row, m.Color, m.Time)
+		if err != nil {
+			log.Println("❌ Error saving moves:", err)
+			return
+		}
+	}
+	if game.Rated && game.Winner != "" {
+		if err = rateGame(tx, game); err != nil {
+			log.Println("❌ Error updating ratings:", err)
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("❌ Error saving game:", err)
+	} else if game.Winner == "" {
+		log.Printf("✓ Game %s saved in progress (%d moves)", game.ID, len(game.Moves))
 	} else {
-		log.Printf("✓ Game saved - Winner: %s", winnerUsername)
+		log.Printf("✓ Game saved - Winner: %s (%d moves)", winnerUsername, len(game.Moves))
 	}
 }
 
@@ -609,11 +1001,11 @@ func (gs *GameServer) getLeaderboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := gs.db.Query(`
-		SELECT winner as username, COUNT(*) as wins 
-		FROM games 
-		WHERE winner IS NOT NULL AND winner != '' 
-		GROUP BY winner 
-		ORDER BY wins DESC 
+		SELECT r.username, r.rating, r.deviation, r.games, COUNT(g.id) as wins
+		FROM ratings r
+		LEFT JOIN games g ON g.winner = r.username
+		GROUP BY r.username, r.rating, r.deviation, r.games
+		ORDER BY r.rating DESC
 		LIMIT 10
 	`)
 	
@@ -627,7 +1019,8 @@ func (gs *GameServer) getLeaderboard(w http.ResponseWriter, r *http.Request) {
 	var leaderboard []LeaderboardEntry
 	for rows.Next() {
 		var entry LeaderboardEntry
-		if err := rows.Scan(&entry.Username, &entry.Wins); err == nil {
+		if err := rows.Scan(&entry.Username, &entry.Rating, &entry.Deviation, &entry.Games, &entry.Wins); err == nil {
+			entry.Rating, entry.Deviation = math.Round(entry.Rating), math.Round(entry.Deviation)
 			leaderboard = append(leaderboard, entry)
 		}
 	}
@@ -643,8 +1036,16 @@ func (gs *GameServer) getLeaderboard(w http.ResponseWriter, r *http.Request) {
 func (gs *GameServer) healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	gs.mutex.RLock()
+	active := 0
+	for _, game := range gs.games {
+		if !game.Imported {
+			active++
+		}
+	}
+	gs.mutex.RUnlock()
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "healthy", "version": "1.0.0", "active_games": len(gs.games), "waiting_players": len(gs.waitingPlayers),
+		"status": "healthy", "version": "1.0.0", "active_games": active, "waiting_players": gs.queue.Len(),
 	})
 	log.Println("✓ Health check")
 }
@@ -694,8 +1095,8 @@ func initDB() *sql.DB {
 		return nil
 	}
 	
-	// Create table if not exists
-	_, err = db.Exec(`
+	// Create tables if not exists, then add columns introduced since
+	schema := []string{`
 		CREATE TABLE IF NOT EXISTS games (
 			id VARCHAR(50) PRIMARY KEY,
 			player1 VARCHAR(100) NOT NULL,
@@ -703,12 +1104,71 @@ func initDB() *sql.DB {
 			winner VARCHAR(100),
 			start_time TIMESTAMP NOT NULL,
 			end_time TIMESTAMP,
-			is_bot BOOLEAN DEFAULT FALSE
+			is_bot BOOLEAN DEFAULT FALSE,
+			difficulty VARCHAR(20),
+			rated BOOLEAN DEFAULT FALSE,
+			player1_rating DOUBLE PRECISION,
+			player2_rating DOUBLE PRECISION,
+			time_control VARCHAR(20),
+			termination VARCHAR(20),
+			deadline TIMESTAMP
 		)
-	`)
-	if err != nil {
-		log.Println("⚠ Error creating table:", err)
-	} else {
+	`, `ALTER TABLE games ADD COLUMN IF NOT EXISTS difficulty VARCHAR(20)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS rated BOOLEAN DEFAULT FALSE`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS player1_rating DOUBLE PRECISION`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS player2_rating DOUBLE PRECISION`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS time_control VARCHAR(20)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS termination VARCHAR(20)`,
+		`ALTER TABLE games ADD COLUMN IF NOT EXISTS deadline TIMESTAMP`, `
+		CREATE TABLE IF NOT EXISTS moves (
+			game_id VARCHAR(50) NOT NULL REFERENCES games(id),
+			ply INTEGER NOT NULL,
+			col INTEGER NOT NULL,
+			row INTEGER NOT NULL,
+			color VARCHAR(10) NOT NULL,
+			played_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (game_id, ply)
+		)
+	`, `
+		CREATE TABLE IF NOT EXISTS move_analysis (
+			game_id VARCHAR(50) NOT NULL,
+			ply INTEGER NOT NULL,
+			col INTEGER NOT NULL,
+			color VARCHAR(10) NOT NULL,
+			class VARCHAR(20) NOT NULL,
+			score INTEGER NOT NULL,
+			best_col INTEGER NOT NULL,
+			best_score INTEGER NOT NULL,
+			PRIMARY KEY (game_id, ply)
+		)
+	`, `
+		CREATE TABLE IF NOT EXISTS ratings (
+			username VARCHAR(100) PRIMARY KEY,
+			rating DOUBLE PRECISION NOT NULL,
+			deviation DOUBLE PRECISION NOT NULL,
+			volatility DOUBLE PRECISION NOT NULL,
+			games INTEGER NOT NULL DEFAULT 0,
+			last_played TIMESTAMP NOT NULL
+		)
+	`, `
+		CREATE TABLE IF NOT EXISTS chat_messages (
+			id SERIAL PRIMARY KEY,
+			game_id VARCHAR(50) NOT NULL,
+			username VARCHAR(100) NOT NULL,
+			message TEXT,
+			original TEXT,
+			emote VARCHAR(20),
+			sent_at TIMESTAMP NOT NULL
+		)
+	`, `CREATE INDEX IF NOT EXISTS chat_messages_game_id ON chat_messages (game_id)`,
+	}
+	for _, stmt := range schema {
+		if _, err = db.Exec(stmt); err != nil {
+			log.Println("⚠ Error creating table:", err)
+			break
+		}
+	}
+	if err == nil {
 		log.Println("✓ Database connected & table ready")
 	}
 	
@@ -760,6 +1220,40 @@ func main() {
 	}
 
 	server := NewGameServer(db, kafkaWriter)
+	if depth, err := strconv.Atoi(getEnv("BOT_DEPTH", "")); err == nil {
+		server.botDepth = depth
+	}
+	if budget, err := time.ParseDuration(getEnv("BOT_TIME_BUDGET", "")); err == nil {
+		server.botBudget = budget
+	}
+	if budget, err := time.ParseDuration(getEnv("ANALYSIS_TIME_BUDGET", "")); err == nil {
+		server.analysisBudget = budget
+	}
+	if timeout, err := time.ParseDuration(getEnv("ROOM_TIMEOUT", "")); err == nil {
+		server.roomTimeout = timeout
+	}
+	if timeout, err := time.ParseDuration(getEnv("CHALLENGE_TIMEOUT", "")); err == nil {
+		server.challengeTimeout = timeout
+	}
+	if ttl, err := time.ParseDuration(getEnv("IMPORT_TTL", "")); err == nil {
+		server.importTTL = ttl
+	}
+	server.chatFilter = newWordFilter(getEnv("CHAT_FILTER", ""))
+	server.moderatorToken = getEnv("MODERATOR_TOKEN", "")
+	matchCfg := matchmaking.DefaultConfig
+	if w, err := strconv.ParseFloat(getEnv("MATCH_INITIAL_WINDOW", ""), 64); err == nil {
+		matchCfg.InitialWindow = w
+	}
+	if w, err := strconv.ParseFloat(getEnv("MATCH_WIDEN_PER_SECOND", ""), 64); err == nil {
+		matchCfg.WidenPerSecond = w
+	}
+	if w, err := strconv.ParseFloat(getEnv("MATCH_MAX_WINDOW", ""), 64); err == nil {
+		matchCfg.MaxWindow = w
+	}
+	server.queue = matchmaking.NewQueue(matchCfg, nil)
+	go server.runMatchmaking(matchInterval)
+	go server.runDeadlines(deadlineSweepInterval)
+	go server.runAnalysis()
 
 	corsMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -777,6 +1271,10 @@ func main() {
 	http.HandleFunc("/ws", server.HandleWebSocket)
 	http.HandleFunc("/leaderboard", corsMiddleware(server.getLeaderboard))
 	http.HandleFunc("/health", corsMiddleware(server.healthCheck))
+	http.HandleFunc("/hint", corsMiddleware(server.getHint))
+	http.HandleFunc("/games/", corsMiddleware(server.handleGames))
+	http.HandleFunc("/players/", corsMiddleware(server.handlePlayers))
+	http.HandleFunc("/moderation/", server.handleModeration) // no CORS: not for browsers
 
 	log.Println("✓ Server ready on :8080")
 	log.Println("📍 http://localhost:8080/health")
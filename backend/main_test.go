@@ -1,13 +1,33 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"connect4/bot"
+	"connect4/engine"
+	"connect4/matchmaking"
+	"connect4/rating"
+
+	"github.com/gorilla/websocket"
 )
 
-func TestCheckWinner(t *testing.T) {
+func TestBotMove(t *testing.T) {
 	gs := &GameServer{
 		games: make(map[string]*GameState),
 	}
+	botMove := func(game *GameState) int {
+		pos, err := positionFromBoard(game.Board, Yellow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gs.chooseBotMove(context.Background(), gs.botFor(game), pos)
+	}
 
 	game := &GameState{
 		Board: make([][]Color, ROWS),
@@ -16,17 +36,17 @@ func TestCheckWinner(t *testing.T) {
 		game.Board[i] = make([]Color, COLS)
 	}
 
-	// Test horizontal win
+	// Bot should block winning move
 	game.Board[5][0] = Red
 	game.Board[5][1] = Red
 	game.Board[5][2] = Red
-	game.Board[5][3] = Red
 
-	if !gs.checkWinner(game, 5, 3) {
-		t.Error("Failed to detect horizontal win")
+	col := botMove(game)
+	if col != 3 {
+		t.Errorf("Bot should block at column 3, got %d", col)
 	}
 
-	// Test vertical win
+	// Bot should take winning move
 	game = &GameState{
 		Board: make([][]Color, ROWS),
 	}
@@ -34,108 +54,1133 @@ func TestCheckWinner(t *testing.T) {
 		game.Board[i] = make([]Color, COLS)
 	}
 
-	game.Board[2][0] = Yellow
-	game.Board[3][0] = Yellow
-	game.Board[4][0] = Yellow
 	game.Board[5][0] = Yellow
+	game.Board[5][1] = Yellow
+	game.Board[5][2] = Yellow
 
-	if !gs.checkWinner(game, 5, 0) {
-		t.Error("Failed to detect vertical win")
+	col = botMove(game)
+	if col != 3 {
+		t.Errorf("Bot should win at column 3, got %d", col)
 	}
+}
 
-	// Test diagonal win
-	game = &GameState{
-		Board: make([][]Color, ROWS),
-	}
-	for i := range game.Board {
-		game.Board[i] = make([]Color, COLS)
-	}
+func TestGenerateID(t *testing.T) {
+	id1 := generateID()
+	id2 := generateID()
 
-	game.Board[2][0] = Red
-	game.Board[3][1] = Red
-	game.Board[4][2] = Red
-	game.Board[5][3] = Red
+	if len(id1) != 8 {
+		t.Errorf("ID length should be 8, got %d", len(id1))
+	}
 
-	if !gs.checkWinner(game, 5, 3) {
-		t.Error("Failed to detect diagonal win")
+	if id1 == id2 {
+		t.Error("Generated IDs should be unique")
 	}
 }
 
-func TestBotMove(t *testing.T) {
+func TestBotDifficulty(t *testing.T) {
 	gs := &GameServer{
-		games: make(map[string]*GameState),
+		games:     make(map[string]*GameState),
+		botBudget: 200 * time.Millisecond,
 	}
 
+	for _, level := range []Difficulty{Easy, Medium, Hard, Perfect} {
+		game := &GameState{
+			Board:      make([][]Color, ROWS),
+			Difficulty: level,
+		}
+		for i := range game.Board {
+			game.Board[i] = make([]Color, COLS)
+		}
+
+		// Every level should still block an immediate win
+		game.Board[5][0] = Red
+		game.Board[5][1] = Red
+		game.Board[5][2] = Red
+
+		pos, err := positionFromBoard(game.Board, Yellow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if col := gs.chooseBotMove(context.Background(), gs.botFor(game), pos); col != 3 {
+			t.Errorf("%s bot should block at column 3, got %d", level, col)
+		}
+	}
+
+	if d, ok := parseDifficulty(""); !ok || d != "" {
+		t.Errorf("Empty difficulty should be left to matchmaking, got %q", d)
+	}
+	if _, ok := parseDifficulty("impossible"); ok {
+		t.Error("Unknown difficulty should be rejected")
+	}
+}
+
+func TestBotMoveDiscardedWhenStale(t *testing.T) {
+	gs := &GameServer{games: make(map[string]*GameState), botBudget: 200 * time.Millisecond}
 	game := &GameState{
-		Board: make([][]Color, ROWS),
+		Board:         make([][]Color, ROWS),
+		Player1:       &Player{Username: "alice", Color: Red},
+		Player2:       &Player{Username: "Bot", Color: Yellow},
+		CurrentPlayer: Yellow,
+		IsBot:         true,
 	}
 	for i := range game.Board {
 		game.Board[i] = make([]Color, COLS)
 	}
 
-	// Bot should block winning move
+	// The board changes after the bot took its snapshot
+	before := gridFromBoard(game.Board)
 	game.Board[5][0] = Red
-	game.Board[5][1] = Red
-	game.Board[5][2] = Red
+	gs.makeBotMove(context.Background(), game, before, 3)
+	if game.Board[5][3] != Empty || game.CurrentPlayer != Yellow {
+		t.Error("Bot move on a stale position should be discarded")
+	}
 
-	col := gs.getBotMove(game)
-	if col != 3 {
-		t.Errorf("Bot should block at column 3, got %d", col)
+	// A cancelled turn never plays
+	defer func(d time.Duration) { botMoveDelay = d }(botMoveDelay)
+	botMoveDelay = 10 * time.Millisecond
+	game.mutex.Lock()
+	gs.startBotTurn(game)
+	gs.cancelBotTurn(game)
+	game.mutex.Unlock()
+	time.Sleep(300 * time.Millisecond)
+	if game.CurrentPlayer != Yellow {
+		t.Error("Cancelled bot turn should not play a move")
 	}
 
-	// Bot should take winning move
-	game = &GameState{
-		Board: make([][]Color, ROWS),
+	// An undisturbed turn plays once the bot has thought
+	game.mutex.Lock()
+	gs.startBotTurn(game)
+	game.mutex.Unlock()
+	time.Sleep(time.Second)
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.CurrentPlayer != Red {
+		t.Error("Bot should have moved after thinking")
+	}
+}
+
+func TestHint(t *testing.T) {
+	gs := &GameServer{games: make(map[string]*GameState), hintLimits: make(map[string]*rateLimiter), botBudget: time.Second, botDepth: 5}
+
+	if _, err := engine.ParseMoves("4482"); err == nil {
+		t.Error("Column 8 should be rejected")
+	}
+
+	// Late in a game red can complete four in column 4
+	pos, err := engine.ParseMoves("5726747645777342466352151223")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := gs.hint(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Best != 3 || !res.Complete || res.ToMove != Red {
+		t.Errorf("Expected complete hint with best column 3 for red, got %+v", res)
+	}
+	for _, h := range res.Hints {
+		if h.Column == 3 && (h.Result != "win" || h.Plies != 1) {
+			t.Errorf("Column 3 should win at once, got %+v", h)
+		}
+		if h.Result == "unknown" {
+			t.Errorf("Column %d should be solved, got %+v", h.Column, h)
+		}
+	}
+
+	// No engine help against a human
+	board := make([][]Color, ROWS)
+	for i := range board {
+		board[i] = make([]Color, COLS)
+	}
+	gs.games["pvp"] = &GameState{ID: "pvp", Board: board, CurrentPlayer: Red}
+	if _, status, err := gs.hintPosition("pvp"); err == nil || status != 403 {
+		t.Errorf("Hints in a human game should be forbidden, got %d %v", status, err)
+	}
+
+	// Each address gets hintBurst requests a minute
+	for i := 1; i <= hintBurst; i++ {
+		rec := httptest.NewRecorder()
+		gs.getHint(rec, httptest.NewRequest("GET", "/hint?moves=8", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("Request %d should reach the parser, got %d", i, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	gs.getHint(rec, httptest.NewRequest("GET", "/hint?moves=4", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Hints beyond the burst should be refused, got %d", rec.Code)
+	}
+}
+
+func TestClassifyMove(t *testing.T) {
+	scores := []engine.MoveScore{{Col: 2, Score: -3}, {Col: 3, Score: 5}, {Col: 4, Score: 2}, {Col: 5, Score: 0}}
+	tests := []struct {
+		col      int
+		complete bool
+		want     string
+	}{
+		{3, true, MoveBest},
+		{4, true, MoveInaccuracy}, // still wins, but slower
+		{5, true, MoveBlunder},    // throws the win away
+		{2, false, MoveBlunder},
+		{4, false, MoveUnknown}, // a faster win may be unsolved
+		{6, true, MoveUnknown},
+	}
+	for _, tt := range tests {
+		if got, _, _ := classify(scores, tt.complete, tt.col); got != tt.want {
+			t.Errorf("Column %d (complete %v): got %s, want %s", tt.col, tt.complete, got, tt.want)
+		}
 	}
+
+	acc := accuracy([]MoveAnnotation{
+		{Color: Red, Class: MoveBest}, {Color: Red, Class: MoveInaccuracy},
+		{Color: Yellow, Class: MoveBlunder}, {Color: Yellow, Class: MoveUnknown},
+	})
+	if acc[Red] != 75 || acc[Yellow] != 0 {
+		t.Errorf("Unexpected accuracy %v", acc)
+	}
+}
+
+func TestAnalyzeMoves(t *testing.T) {
+	var moves []int
+	for _, ch := range "57267476457773424663521512234" {
+		moves = append(moves, int(ch-'1'))
+	}
+	a := analyzeMoves("g1", moves, 50*time.Millisecond)
+	if len(a.Moves) != len(moves) {
+		t.Fatalf("Expected %d annotations, got %d", len(moves), len(a.Moves))
+	}
+	last := a.Moves[len(moves)-1]
+	if last.Class != MoveBest || last.Color != Red || last.Ply != len(moves) {
+		t.Errorf("Winning move should be best for red, got %+v", last)
+	}
+	if _, ok := a.Accuracy[Red]; !ok {
+		t.Errorf("Red should have an accuracy, got %v", a.Accuracy)
+	}
+}
+
+func TestMoveHistory(t *testing.T) {
+	gs := &GameServer{games: make(map[string]*GameState)}
+	game := &GameState{Board: make([][]Color, ROWS)}
 	for i := range game.Board {
 		game.Board[i] = make([]Color, COLS)
 	}
 
-	game.Board[5][0] = Yellow
-	game.Board[5][1] = Yellow
-	game.Board[5][2] = Yellow
+	for i, col := range []int{3, 3, 4} {
+		color := Red
+		if i%2 == 1 {
+			color = Yellow
+		}
+		if _, err := gs.playDisc(game, color, col); err != nil {
+			t.Fatal(err)
+		}
+	}
 
-	col = gs.getBotMove(game)
-	if col != 3 {
-		t.Errorf("Bot should win at column 3, got %d", col)
+	if len(game.Moves) != 3 {
+		t.Fatalf("Expected 3 moves recorded, got %d", len(game.Moves))
+	}
+	second := game.Moves[1]
+	if second.Ply != 2 || second.Column != 3 || second.Row != ROWS-2 || second.Color != Yellow || second.Time.IsZero() {
+		t.Errorf("Unexpected second move %+v", second)
+	}
+	if got := columns(game.Moves); len(got) != 3 || got[2] != 4 {
+		t.Errorf("Expected columns [3 3 4], got %v", got)
 	}
 }
 
-func TestIsBoardFull(t *testing.T) {
-	gs := &GameServer{}
-
+// finishedGame plays cols for alternating colours, starting with red.
+func finishedGame(t *testing.T, gs *GameServer, id string, cols ...int) *GameState {
 	game := &GameState{
-		Board: make([][]Color, ROWS),
+		ID: id, Board: make([][]Color, ROWS), StartTime: time.Now(),
+		Player1: &Player{Username: "alice", Color: Red},
+		Player2: &Player{Username: "bob", Color: Yellow},
 	}
 	for i := range game.Board {
 		game.Board[i] = make([]Color, COLS)
 	}
+	gs.games[id] = game
+	for i, col := range cols {
+		color := Red
+		if i%2 == 1 {
+			color = Yellow
+		}
+		if _, err := gs.playDisc(game, color, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return game
+}
+
+func TestGetGame(t *testing.T) {
+	gs := &GameServer{games: make(map[string]*GameState)}
+	finishedGame(t, gs, "g1", 0, 1, 0, 1, 0, 1, 0)
 
-	if gs.isBoardFull(game) {
-		t.Error("Empty board should not be full")
+	rec := httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/g1", nil))
+	var got GameRecord
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Winner != "alice" || got.Result != "red" || len(got.Moves) != 7 || got.Moves[6].Ply != 7 {
+		t.Errorf("Unexpected game record %+v", got)
 	}
 
-	// Fill board
-	for i := 0; i < ROWS; i++ {
-		for j := 0; j < COLS; j++ {
-			game.Board[i][j] = Red
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Missing game should be 404, got %d", rec.Code)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	gs := &GameServer{
+		games:    make(map[string]*GameState),
+		upgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+	}
+	finishedGame(t, gs, "g1", 0, 1, 0, 1, 0, 1, 0)
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+	conn, read := dialTest(t, srv)
+
+	conn.WriteJSON(Message{Type: "replay", GameID: "g1", Speed: maxReplaySpeed})
+	if msg := read(); msg.Type != "replay_start" || msg.Game == nil || len(msg.Game.Moves) != 7 {
+		t.Fatalf("Expected replay_start with 7 moves, got %+v", msg)
+	}
+	for ply := 0; ply <= 7; ply++ {
+		if msg := read(); msg.Type != "move" || msg.Ply != ply {
+			t.Fatalf("Expected move at ply %d, got %+v", ply, msg)
 		}
 	}
+	if msg := read(); msg.Type != "game_over" || msg.Winner != "red" || len(msg.WinLine) != 4 {
+		t.Fatalf("Expected red win with its line, got %+v", msg)
+	}
 
-	if !gs.isBoardFull(game) {
-		t.Error("Full board not detected")
+	// Seeking back shows that position straight away
+	conn.WriteJSON(Message{Type: "replay_pause"})
+	conn.WriteJSON(Message{Type: "replay_seek", Ply: 2})
+	msg := read()
+	if msg.Type != "move" || msg.Ply != 2 || msg.Board[ROWS-1][1] != Yellow || msg.CurrentPlayer != Red {
+		t.Fatalf("Expected board after ply 2, got %+v", msg)
 	}
 }
 
-func TestGenerateID(t *testing.T) {
-	id1 := generateID()
-	id2 := generateID()
+func TestAnalysisQueue(t *testing.T) {
+	gs := NewGameServer(nil, nil)
+	gs.analyses, gs.analysisBudget = make(chan analysisJob, 1), 10*time.Millisecond
+	finishedGame(t, gs, "g1", 0, 1, 0, 1, 0, 1, 0)
+	finishedGame(t, gs, "g2", 6, 5, 6, 5, 6, 5, 6)
 
-	if len(id1) != 8 {
-		t.Errorf("ID length should be 8, got %d", len(id1))
+	// g1 took the only place in the queue, so g2 is turned away
+	for _, want := range []struct {
+		id   string
+		code int
+	}{{"g1", http.StatusAccepted}, {"g2", http.StatusServiceUnavailable}, {"g1", http.StatusAccepted}} {
+		rec := httptest.NewRecorder()
+		gs.handleGames(rec, httptest.NewRequest("GET", "/games/"+want.id+"/analysis", nil))
+		if rec.Code != want.code {
+			t.Errorf("Expected %d for %s, got %d", want.code, want.id, rec.Code)
+		}
+	}
+	if len(gs.analyses) != 1 {
+		t.Errorf("Expected one queued analysis, got %d", len(gs.analyses))
 	}
 
-	if id1 == id2 {
-		t.Error("Generated IDs should be unique")
+	// Once the worker drains the queue g2 gets its place
+	go gs.runAnalysis()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		gs.handleGames(rec, httptest.NewRequest("GET", "/games/g2/analysis", nil))
+		if rec.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("g2 was never analysed, last status %d", rec.Code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNotationExportImport(t *testing.T) {
+	gs := NewGameServer(nil, nil)
+	finishedGame(t, gs, "g1", 0, 1, 0, 1, 0, 1, 0)
+
+	rec := httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/g1/notation?format=moves", nil))
+	if got := rec.Body.String(); got != "1212121\n" {
+		t.Errorf("Expected column string, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/g1/notation", nil))
+	text := rec.Body.String()
+	if !strings.Contains(text, `[Red "alice"]`) || !strings.Contains(text, `[Result "1-0"]`) {
+		t.Errorf("Record is missing tags:\n%s", text)
 	}
-}
\ No newline at end of file
+
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("POST", "/games/import", strings.NewReader(text)))
+	var imported GameRecord
+	json.NewDecoder(rec.Body).Decode(&imported)
+	if rec.Code != http.StatusCreated || imported.Result != "red" || imported.Player2 != "bob" || len(imported.Moves) != 7 {
+		t.Errorf("Unexpected import %d %+v", rec.Code, imported)
+	}
+
+	// Without a result tag the result comes from the board
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("POST", "/games/import", strings.NewReader("1212121")))
+	var untagged GameRecord
+	json.NewDecoder(rec.Body).Decode(&untagged)
+	if rec.Code != http.StatusCreated || untagged.Result != "red" || untagged.EndTime == nil {
+		t.Errorf("A bare move string ending in four should import as finished, got %d %+v", rec.Code, untagged)
+	}
+
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("POST", "/games/import", strings.NewReader("44444444")))
+	var bad map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&bad)
+	if rec.Code != http.StatusUnprocessableEntity || bad["ply"] != float64(7) {
+		t.Errorf("Illegal sequence should fail at ply 7, got %d %v", rec.Code, bad)
+	}
+
+	// Imports are analysed on request, left out of /health and expire
+	if a := gs.games[imported.ID].Analysis; a != nil {
+		t.Errorf("Imports should not be analysed until asked, got %+v", a)
+	}
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/"+imported.ID+"/analysis", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Asking for the analysis should start it, got %d", rec.Code)
+	}
+	var health map[string]interface{}
+	rec = httptest.NewRecorder()
+	gs.healthCheck(rec, httptest.NewRequest("GET", "/health", nil))
+	json.NewDecoder(rec.Body).Decode(&health)
+	if health["active_games"] != float64(1) {
+		t.Errorf("Imports should not count as active games, got %v", health["active_games"])
+	}
+	gs.expireImport(gs.games[imported.ID])
+	if gs.games[imported.ID] != nil {
+		t.Error("Expired import should be dropped")
+	}
+
+	// Each address gets importBurst imports a minute, counting the three above
+	for i := 4; i <= importBurst; i++ {
+		rec = httptest.NewRecorder()
+		gs.handleGames(rec, httptest.NewRequest("POST", "/games/import", strings.NewReader("4")))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Import %d should be allowed, got %d", i, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("POST", "/games/import", strings.NewReader("4")))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Imports beyond the burst should be refused, got %d", rec.Code)
+	}
+}
+
+func TestSpectate(t *testing.T) {
+	gs := &GameServer{
+		games:       make(map[string]*GameState),
+		playerGames: make(map[string]*GameState),
+		upgrader:    websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+	}
+	game := finishedGame(t, gs, "live", 3)
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+	conn, read := dialTest(t, srv)
+
+	conn.WriteJSON(Message{Type: "spectate", GameID: "live"})
+	if msg := read(); msg.Type != "spectating" || msg.Spectators != 1 || msg.Board[ROWS-1][3] != Red {
+		t.Fatalf("Expected current position with 1 spectator, got %+v", msg)
+	}
+	if msg := read(); msg.Type != "spectators" || msg.Spectators != 1 {
+		t.Fatalf("Expected spectator count, got %+v", msg)
+	}
+
+	conn.WriteJSON(Message{Type: "move", Column: 0})
+	if msg := read(); msg.Type != "error" || msg.Message != "Spectators cannot move" {
+		t.Fatalf("Spectator move should be refused, got %+v", msg)
+	}
+
+	game.mutex.Lock()
+	gs.playDisc(game, Yellow, 3)
+	gs.broadcastMove(game)
+	game.mutex.Unlock()
+	if msg := read(); msg.Type != "move" || msg.Board[ROWS-2][3] != Yellow {
+		t.Fatalf("Spectator should receive moves, got %+v", msg)
+	}
+
+	conn.WriteJSON(Message{Type: "unspectate"})
+	time.Sleep(100 * time.Millisecond)
+	game.mutex.RLock()
+	defer game.mutex.RUnlock()
+	if len(game.Spectators) != 0 {
+		t.Errorf("Expected no spectators after unspectate, got %d", len(game.Spectators))
+	}
+}
+
+func TestLiveGames(t *testing.T) {
+	gs := &GameServer{games: make(map[string]*GameState)}
+	for i, id := range []string{"a", "b", "c"} {
+		game := finishedGame(t, gs, id, 3)
+		game.StartTime = time.Now().Add(time.Duration(i) * time.Minute)
+	}
+	finishedGame(t, gs, "over", 0, 1, 0, 1, 0, 1, 0)
+
+	rec := httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/live?page=2&per_page=2", nil))
+	var page LiveGamesPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Games) != 1 || page.Games[0].ID != "a" {
+		t.Fatalf("Expected the oldest live game alone on page 2, got %+v", page)
+	}
+	if g := page.Games[0]; g.Moves != 1 || g.Player2 != "bob" {
+		t.Errorf("Unexpected summary %+v", g)
+	}
+}
+
+// dialTest connects a WebSocket client to the server and returns a reader
+// that fails the test if no message arrives in time.
+func dialTest(t *testing.T, srv *httptest.Server) (*websocket.Conn, func() Message) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, func() Message {
+		var msg Message
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+}
+
+func TestPrivateRooms(t *testing.T) {
+	gs := NewGameServer(nil, nil)
+	gs.roomTimeout = 200 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+
+	host, readHost := dialTest(t, srv)
+	guest, readGuest := dialTest(t, srv)
+
+	host.WriteJSON(Message{Type: "create_room", Username: "alice"})
+	created := readHost()
+	if created.Type != "room_created" || len(created.RoomCode) != roomCodeLength {
+		t.Fatalf("Expected a room code, got %+v", created)
+	}
+
+	host.WriteJSON(Message{Type: "join_room", Username: "alice", RoomCode: created.RoomCode})
+	if msg := readHost(); msg.Type != "error" {
+		t.Errorf("Joining your own room should fail, got %+v", msg)
+	}
+
+	guest.WriteJSON(Message{Type: "join_room", Username: "bob", RoomCode: strings.ToLower(created.RoomCode)})
+	if msg := readHost(); msg.Type != "game_start" || msg.Opponent != "bob" || msg.Color != Red {
+		t.Errorf("Host should start as red against bob, got %+v", msg)
+	}
+	if msg := readGuest(); msg.Type != "game_start" || msg.Opponent != "alice" || msg.Color != Yellow {
+		t.Errorf("Guest should start as yellow against alice, got %+v", msg)
+	}
+
+	// An unused room expires instead of falling back to the bot
+	host.WriteJSON(Message{Type: "create_room", Username: "carol"})
+	code := readHost().RoomCode
+	if msg := readHost(); msg.Type != "room_expired" || msg.RoomCode != code {
+		t.Errorf("Expected room %s to expire, got %+v", code, msg)
+	}
+	guest.WriteJSON(Message{Type: "join_room", Username: "dave", RoomCode: code})
+	if msg := readGuest(); msg.Type != "error" {
+		t.Errorf("Expired room should not be joinable, got %+v", msg)
+	}
+
+	// The guest is told when the host leaves a room game
+	host, readHost = dialTest(t, srv)
+	guest, readGuest = dialTest(t, srv)
+	host.WriteJSON(Message{Type: "create_room", Username: "erin"})
+	guest.WriteJSON(Message{Type: "join_room", Username: "frank", RoomCode: readHost().RoomCode})
+	readHost()
+	readGuest()
+	host.Close()
+	if msg := readGuest(); msg.Type != "opponent_disconnected" {
+		t.Errorf("Guest should hear that the host left, got %+v", msg)
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	gs := NewGameServer(nil, nil)
+	gs.challengeTimeout = 200 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+
+	alice, readAlice := dialTest(t, srv)
+	bob, readBob := dialTest(t, srv)
+
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob"})
+	if msg := readAlice(); msg.Type != "error" || msg.Message != "bob is not online" {
+		t.Fatalf("Offline user should not be challengeable, got %+v", msg)
+	}
+
+	// Any message with a username marks the connection online
+	bob.WriteJSON(Message{Type: "decline", Username: "bob"})
+	readBob()
+
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob"})
+	if msg := readAlice(); msg.Type != "challenge_sent" {
+		t.Fatalf("Expected challenge_sent, got %+v", msg)
+	}
+	first := readBob()
+	if first.Type != "challenge" || first.Username != "alice" {
+		t.Fatalf("Bob should be challenged by alice, got %+v", first)
+	}
+	bob.WriteJSON(Message{Type: "decline", ChallengeID: first.ChallengeID})
+	if msg := readAlice(); msg.Type != "challenge_declined" || msg.Opponent != "bob" {
+		t.Fatalf("Expected decline, got %+v", msg)
+	}
+
+	// Unanswered challenges expire for both sides
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob"})
+	readAlice()
+	readBob()
+	if msg := readAlice(); msg.Type != "challenge_expired" {
+		t.Fatalf("Expected expiry, got %+v", msg)
+	}
+	readBob()
+
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob"})
+	readAlice()
+	second := readBob()
+	bob.WriteJSON(Message{Type: "accept", ChallengeID: second.ChallengeID})
+	if msg := readAlice(); msg.Type != "game_start" || msg.Color != Red || msg.Opponent != "bob" {
+		t.Errorf("Challenger should start as red, got %+v", msg)
+	}
+	if msg := readBob(); msg.Type != "game_start" || msg.Color != Yellow {
+		t.Errorf("Challenged player should start as yellow, got %+v", msg)
+	}
+
+	// Bob is told when the challenger leaves
+	alice.Close()
+	if msg := readBob(); msg.Type != "opponent_disconnected" {
+		t.Errorf("Bob should hear that alice left, got %+v", msg)
+	}
+
+	// A challenge is withdrawn when the challenger disconnects
+	carol, readCarol := dialTest(t, srv)
+	dave, readDave := dialTest(t, srv)
+	dave.WriteJSON(Message{Type: "decline", Username: "dave"})
+	readDave()
+	carol.WriteJSON(Message{Type: "challenge", Username: "carol", Opponent: "dave"})
+	readCarol()
+	third := readDave()
+	carol.Close()
+	if msg := readDave(); msg.Type != "challenge_expired" || msg.ChallengeID != third.ChallengeID {
+		t.Fatalf("Dave should see the challenge withdrawn, got %+v", msg)
+	}
+	dave.WriteJSON(Message{Type: "accept", ChallengeID: third.ChallengeID})
+	if msg := readDave(); msg.Type != "error" {
+		t.Errorf("A withdrawn challenge should not start a game, got %+v", msg)
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestMatchmaking(t *testing.T) {
+	gs := NewGameServer(nil, nil)
+	clock := &fakeClock{now: time.Now()}
+	gs.queue = matchmaking.NewQueue(matchmaking.Config{InitialWindow: 50, WidenPerSecond: 25, MaxWindow: 300}, clock)
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+
+	alice, readAlice := dialTest(t, srv)
+	bob, readBob := dialTest(t, srv)
+	carol, readCarol := dialTest(t, srv)
+
+	alice.WriteJSON(Message{Type: "join", Username: "alice"})
+	if msg := readAlice(); msg.Type != "waiting" {
+		t.Fatalf("Expected waiting, got %+v", msg)
+	}
+	bob.WriteJSON(Message{Type: "join", Username: "bob"})
+	if msg := readAlice(); msg.Type != "game_start" || msg.Color != Red || msg.Opponent != "bob" {
+		t.Errorf("Longest waiting player should start as red, got %+v", msg)
+	} else if !msg.Rated || msg.OpponentRating != rating.DefaultRating {
+		t.Errorf("Matched games should be rated and show the opponent's rating, got %+v", msg)
+	}
+	if msg := readBob(); msg.Type != "game_start" || msg.Opponent != "alice" {
+		t.Errorf("Expected game_start against alice, got %+v", msg)
+	}
+
+	carol.WriteJSON(Message{Type: "join", Username: "carol"})
+	if msg := readCarol(); msg.Type != "waiting" {
+		t.Fatalf("Expected waiting, got %+v", msg)
+	}
+	match := func() {
+		gs.mutex.Lock()
+		defer gs.mutex.Unlock()
+		gs.matchQueued()
+	}
+
+	// The bot only steps in once the window passes its limit
+	clock.now = clock.now.Add(10 * time.Second)
+	match()
+	if gs.queue.Len() != 1 {
+		t.Fatalf("Carol should still be searching at the limit")
+	}
+	clock.now = clock.now.Add(time.Second)
+	match()
+	msg := readCarol()
+	if msg.Type != "game_start" || !strings.HasPrefix(msg.Opponent, "Bot") || msg.Difficulty != Hard {
+		t.Errorf("Expected a hard bot for a 1500 player, got %+v", msg)
+	}
+	if msg.Rated || msg.OpponentRating != 0 {
+		t.Errorf("Bot games should be unrated, got %+v", msg)
+	}
+	if gs.queue.Len() != 0 || len(gs.waiting) != 0 {
+		t.Errorf("Queue should be empty, got %d", gs.queue.Len())
+	}
+}
+
+func TestNewRatings(t *testing.T) {
+	red, yellow := newRatings(rating.Default(), rating.Default(), string(Red))
+	if red.Rating <= rating.DefaultRating || yellow.Rating >= rating.DefaultRating {
+		t.Errorf("Red won: got red %.1f, yellow %.1f", red.Rating, yellow.Rating)
+	}
+	red, yellow = newRatings(rating.Default(), rating.Default(), string(Yellow))
+	if red.Rating >= yellow.Rating {
+		t.Errorf("Yellow won: got red %.1f, yellow %.1f", red.Rating, yellow.Rating)
+	}
+
+	strong := rating.Rating{Rating: 1800, Deviation: 60, Volatility: rating.DefaultVolatility}
+	weak := rating.Rating{Rating: 1400, Deviation: 60, Volatility: rating.DefaultVolatility}
+	red, yellow = newRatings(strong, weak, "draw")
+	if red.Rating >= strong.Rating || yellow.Rating <= weak.Rating {
+		t.Errorf("A draw should cost the stronger player, got red %.1f, yellow %.1f", red.Rating, yellow.Rating)
+	}
+}
+
+func TestPlayerProfile(t *testing.T) {
+	start := time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+	game := func(id, result string, isBot, rated bool, moves int, r float64) PlayerGame {
+		end := start.Add(time.Duration(moves) * 10 * time.Second)
+		return PlayerGame{ID: id, Result: result, IsBot: isBot, Rated: rated, Moves: moves, Rating: r, StartTime: start, EndTime: &end}
+	}
+	p := buildProfile("alice", []PlayerGame{
+		game("g1", "win", false, true, 10, 1662),
+		game("g2", "win", true, false, 20, 0),
+		game("g3", "win", false, false, 30, 0),
+		game("g4", "loss", false, true, 40, 1590),
+		game("g5", "draw", true, false, 42, 0),
+		game("g6", "win", false, true, 8, 1640),
+	})
+
+	if p.PvP != (GameStats{Wins: 3, Losses: 1}) || p.Bot != (GameStats{Wins: 1, Draws: 1}) {
+		t.Errorf("Expected 3-1-0 against humans and 1-0-1 against bots, got %+v and %+v", p.PvP, p.Bot)
+	}
+	if p.LongestStreak != 3 {
+		t.Errorf("Longest streak should be 3, got %d", p.LongestStreak)
+	}
+	if p.AverageMoves != 25 || p.AverageDuration != 250 {
+		t.Errorf("Expected 25 moves and 250s on average, got %v and %v", p.AverageMoves, p.AverageDuration)
+	}
+	if len(p.RatingHistory) != 3 || p.RatingHistory[1].GameID != "g4" || p.RatingHistory[1].Rating != 1590 {
+		t.Errorf("History should hold the three rated games in order, got %+v", p.RatingHistory)
+	}
+	if len(p.RecentGames) != 6 || p.RecentGames[0].ID != "g6" {
+		t.Errorf("Recent games should be newest first, got %+v", p.RecentGames)
+	}
+
+	gs := NewGameServer(nil, nil)
+	rec := httptest.NewRecorder()
+	gs.handlePlayers(rec, httptest.NewRequest("GET", "/players/nobody", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Unknown player should be 404, got %d", rec.Code)
+	}
+}
+
+func TestTimeControl(t *testing.T) {
+	for s, want := range map[string]TimeControl{
+		"":        {},
+		"-":       {},
+		"180+2":   {Base: 180 * time.Second, Increment: 2 * time.Second},
+		"60":      {Base: 60 * time.Second},
+		"30/move": {Base: 30 * time.Second, PerMove: true},
+	} {
+		tc, err := parseTimeControl(s)
+		if err != nil || tc != want {
+			t.Errorf("parseTimeControl(%q) = %+v, %v; want %+v", s, tc, err, want)
+		}
+		if s != "" && s != "60" && tc.String() != s {
+			t.Errorf("%+v formats as %q, want %q", tc, tc.String(), s)
+		}
+	}
+	for _, s := range []string{"fast", "0+2", "-5/move", "180+x"} {
+		if _, err := parseTimeControl(s); err == nil {
+			t.Errorf("parseTimeControl(%q) should fail", s)
+		}
+	}
+
+	gs := NewGameServer(nil, nil)
+	newGame := func(tc TimeControl) *GameState {
+		gs.mutex.Lock()
+		defer gs.mutex.Unlock()
+		return gs.createGame(&Player{Username: "alice", TimeControl: tc}, &Player{Username: "bob"}, nil, false)
+	}
+
+	// The increment is added after each move
+	game := newGame(TimeControl{Base: time.Second, Increment: 500 * time.Millisecond})
+	gs.handleMove(game, game.Player1, 3)
+	game.mutex.RLock()
+	left := game.Clock[Red]
+	ms := clocks(game)
+	game.mutex.RUnlock()
+	if left <= time.Second || left > 1500*time.Millisecond {
+		t.Errorf("Red should have just under 1.5s after the increment, got %v", left)
+	}
+	if ms[Yellow] > 1000 || ms[Red] != left.Milliseconds() {
+		t.Errorf("Clocks should show yellow running and red stopped, got %v", ms)
+	}
+
+	// The flag falls on whoever is to move
+	game = newGame(TimeControl{Base: 50 * time.Millisecond, PerMove: true})
+	time.Sleep(150 * time.Millisecond)
+	game.mutex.RLock()
+	winner, termination := game.Winner, game.Termination
+	game.mutex.RUnlock()
+	if winner != string(Yellow) || termination != EndTimeout {
+		t.Errorf("Red should lose on time, got %q by %q", winner, termination)
+	}
+	if rec := recordNotation(recordOf(game)); rec.Tags["Termination"] != EndTimeout {
+		t.Errorf("Notation should record the timeout, got %v", rec.Tags)
+	}
+}
+
+func TestCorrespondence(t *testing.T) {
+	if tc, err := parseTimeControl("24h/move"); err != nil || !tc.Correspondence || tc.String() != "24h/move" {
+		t.Fatalf("parseTimeControl(24h/move) = %+v, %v", tc, err)
+	}
+	if _, err := parseTimeControl("1000h/move"); err == nil {
+		t.Error("Moves longer than two weeks should be refused")
+	}
+
+	gs := NewGameServer(nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+
+	alice, readAlice := dialTest(t, srv)
+	bob, readBob := dialTest(t, srv)
+	bob.WriteJSON(Message{Type: "decline", Username: "bob"})
+	readBob()
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob", TimeControl: "24h/move"})
+	readAlice()
+	bob.WriteJSON(Message{Type: "accept", ChallengeID: readBob().ChallengeID})
+	start := readAlice()
+	if start.Type != "game_start" || start.TimeControl != "24h/move" || start.Clock[Red] < 23*3600*1000 {
+		t.Fatalf("Expected a correspondence game, got %+v", start)
+	}
+	readBob()
+
+	alice.WriteJSON(Message{Type: "move", Column: 3})
+	readAlice()
+	readBob()
+
+	// Leaving does not forfeit
+	alice.Close()
+	gs.mutex.RLock()
+	game := gs.games[start.GameID]
+	gs.mutex.RUnlock()
+	for i := 0; ; i++ {
+		game.mutex.RLock()
+		gone := game.Player1.Conn == nil
+		game.mutex.RUnlock()
+		if gone {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Alice's connection should be dropped from the game")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var listed []CorrespondenceGame
+	rec := httptest.NewRecorder()
+	gs.handlePlayers(rec, httptest.NewRequest("GET", "/players/bob/games?status=your_turn", nil))
+	json.NewDecoder(rec.Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].ID != start.GameID || listed[0].Opponent != "alice" || listed[0].Deadline == nil {
+		t.Fatalf("Bob should have one game to move in, got %+v", listed)
+	}
+	rec = httptest.NewRecorder()
+	gs.handlePlayers(rec, httptest.NewRequest("GET", "/players/alice/games?status=your_turn", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("Alice is waiting for bob, got %s", body)
+	}
+	rec = httptest.NewRecorder()
+	gs.handlePlayers(rec, httptest.NewRequest("GET", "/players/bob/games?status=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Unknown status should be a bad request, got %d", rec.Code)
+	}
+
+	bob.WriteJSON(Message{Type: "move", Column: 3})
+	readBob()
+
+	// Alice comes back on a new connection and picks up where the game is
+	alice, readAlice = dialTest(t, srv)
+	alice.WriteJSON(Message{Type: "resume", Username: "alice", GameID: start.GameID})
+	resumed := readAlice()
+	if resumed.Type != "resumed" || resumed.Color != Red || resumed.CurrentPlayer != Red || resumed.Board[ROWS-2][3] != Yellow {
+		t.Fatalf("Expected the position after two moves, got %+v", resumed)
+	}
+	alice.WriteJSON(Message{Type: "move", GameID: start.GameID, Column: 4})
+	if msg := readAlice(); msg.Type != "move" || msg.Board[ROWS-1][4] != Red {
+		t.Errorf("Alice should be able to move after resuming, got %+v", msg)
+	}
+	readBob()
+
+	alice.WriteJSON(Message{Type: "resume", Username: "carol", GameID: start.GameID})
+	if msg := readAlice(); msg.Type != "error" {
+		t.Errorf("Only the players can resume a game, got %+v", msg)
+	}
+}
+
+func TestResignDrawRematch(t *testing.T) {
+	gs := NewGameServer(nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+
+	alice, readAlice := dialTest(t, srv)
+	bob, readBob := dialTest(t, srv)
+	bob.WriteJSON(Message{Type: "decline", Username: "bob"})
+	readBob()
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob"})
+	readAlice()
+	bob.WriteJSON(Message{Type: "accept", ChallengeID: readBob().ChallengeID})
+	first := readAlice()
+	readBob()
+
+	// A declined offer leaves the game going
+	bob.WriteJSON(Message{Type: "offer_draw"})
+	if msg := readAlice(); msg.Type != "draw_offered" || msg.Username != "bob" {
+		t.Fatalf("Alice should be offered a draw, got %+v", msg)
+	}
+	alice.WriteJSON(Message{Type: "decline_draw"})
+	if msg := readBob(); msg.Type != "draw_declined" {
+		t.Fatalf("Bob should hear the decline, got %+v", msg)
+	}
+	alice.WriteJSON(Message{Type: "accept_draw"})
+	if msg := readAlice(); msg.Type != "error" {
+		t.Errorf("There is no offer left to accept, got %+v", msg)
+	}
+
+	alice.WriteJSON(Message{Type: "offer_draw"})
+	readBob()
+	bob.WriteJSON(Message{Type: "accept_draw"})
+	for _, read := range []func() Message{readAlice, readBob} {
+		if msg := read(); msg.Type != "game_over" || msg.Winner != "draw" || msg.Termination != EndAgreedDraw {
+			t.Fatalf("Expected an agreed draw, got %+v", msg)
+		}
+	}
+
+	// Both players must ask for the rematch, which swaps colours
+	alice.WriteJSON(Message{Type: "rematch"})
+	if msg := readBob(); msg.Type != "rematch_offered" || msg.Username != "alice" {
+		t.Fatalf("Bob should be offered a rematch, got %+v", msg)
+	}
+	bob.WriteJSON(Message{Type: "rematch"})
+	second := readAlice()
+	if second.Type != "game_start" || second.Color != Yellow || second.GameID == first.GameID {
+		t.Fatalf("Alice should play yellow in a new game, got %+v", second)
+	}
+	if msg := readBob(); msg.Type != "game_start" || msg.Color != Red {
+		t.Fatalf("Bob should play red, got %+v", msg)
+	}
+
+	// Alice's connection follows the rematch bob started
+	alice.WriteJSON(Message{Type: "resign"})
+	for _, read := range []func() Message{readAlice, readBob} {
+		if msg := read(); msg.Type != "game_over" || msg.Winner != string(Red) || msg.Termination != EndResigned {
+			t.Fatalf("Alice should lose by resignation, got %+v", msg)
+		}
+	}
+	if rec := recordOf(gs.games[second.GameID]); rec.Termination != EndResigned || rec.Winner != "bob" {
+		t.Errorf("The record should show the resignation, got %+v", rec)
+	}
+	if old := gs.games[first.GameID]; old.Player1.Username != "alice" || old.Player1.Color != Red || old.Player2.Color != Yellow {
+		t.Errorf("The rematch should leave the first game's seats alone, got %+v and %+v", old.Player1, old.Player2)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	defer func(d time.Duration) { botMoveDelay = d }(botMoveDelay)
+	botMoveDelay = 10 * time.Millisecond
+	gs := NewGameServer(nil, nil)
+	newGame := func(p2 *Player, b bot.Bot, rated bool) *GameState {
+		gs.mutex.Lock()
+		defer gs.mutex.Unlock()
+		return gs.createGame(&Player{Username: "alice", Difficulty: Easy}, p2, b, rated)
+	}
+	moves := func(game *GameState) (int, Color) {
+		game.mutex.RLock()
+		defer game.mutex.RUnlock()
+		return len(game.Moves), game.CurrentPlayer
+	}
+
+	// The bot takes back its reply along with the player's move
+	b := gs.newBot(Easy)
+	game := newGame(&Player{Username: botUsername(b)}, b, false)
+	gs.handleMove(game, game.Player1, 3)
+	for i := 0; ; i++ {
+		if n, _ := moves(game); n == 2 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Bot should have replied")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := gs.requestUndo(game, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if n, turn := moves(game); n != 0 || turn != Red || game.Board[ROWS-1][3] != Empty {
+		t.Errorf("Both moves should be taken back, got %d moves with %s to play", n, turn)
+	}
+
+	// A human opponent must agree
+	game = newGame(&Player{Username: "bob"}, nil, false)
+	if err := gs.requestUndo(game, "alice"); err != errNothingToUndo {
+		t.Errorf("Nothing to take back before moving, got %v", err)
+	}
+	gs.handleMove(game, game.Player1, 3)
+	gs.requestUndo(game, "alice")
+	if err := gs.requestUndo(game, "alice"); err != errUndoRequested {
+		t.Errorf("Asking twice should fail, got %v", err)
+	}
+	gs.answerUndo(game, "bob", true)
+	if n, turn := moves(game); n != 0 || turn != Red {
+		t.Errorf("Alice's move should be taken back, got %d moves with %s to play", n, turn)
+	}
+	gs.handleMove(game, game.Player1, 3)
+	gs.handleMove(game, game.Player2, 4)
+	gs.requestUndo(game, "alice")
+	gs.answerUndo(game, "bob", false)
+	if n, _ := moves(game); n != 2 {
+		t.Errorf("A refused take-back should leave the moves, got %d", n)
+	}
+	if err := gs.answerUndo(game, "bob", true); err != errNoUndoRequest {
+		t.Errorf("The request should be gone once answered, got %v", err)
+	}
+
+	game = newGame(&Player{Username: "bob"}, nil, true)
+	gs.handleMove(game, game.Player1, 3)
+	if err := gs.requestUndo(game, "alice"); err != errUndoRated {
+		t.Errorf("Rated games should refuse take-backs, got %v", err)
+	}
+}
+
+func TestChat(t *testing.T) {
+	filter := newWordFilter(" Darn, heck ")
+	if got, masked := filter.apply("Darn it, what the HECK... darned"); got != "**** it, what the ****... darned" || !masked {
+		t.Errorf("Filter gave %q, %v", got, masked)
+	}
+	if _, masked := filter.apply("nice move"); masked {
+		t.Error("Clean text should pass the filter")
+	}
+
+	limit := rateLimiter{burst: chatBurst, window: chatWindow}
+	now := time.Now()
+	for i := 0; i < chatBurst; i++ {
+		if !limit.allow(now) {
+			t.Fatalf("Line %d should be allowed", i+1)
+		}
+	}
+	if limit.allow(now) {
+		t.Error("Lines beyond the burst should be refused")
+	}
+	if !limit.allow(now.Add(chatWindow)) {
+		t.Error("The limit should reset after the window")
+	}
+
+	gs := NewGameServer(nil, nil)
+	gs.chatFilter = filter
+	srv := httptest.NewServer(http.HandlerFunc(gs.HandleWebSocket))
+	defer srv.Close()
+
+	alice, readAlice := dialTest(t, srv)
+	bob, readBob := dialTest(t, srv)
+	bob.WriteJSON(Message{Type: "decline", Username: "bob"})
+	readBob()
+	alice.WriteJSON(Message{Type: "challenge", Username: "alice", Opponent: "bob"})
+	readAlice()
+	bob.WriteJSON(Message{Type: "accept", ChallengeID: readBob().ChallengeID})
+	gameID := readAlice().GameID
+	readBob()
+
+	watcher, readWatcher := dialTest(t, srv)
+	watcher.WriteJSON(Message{Type: "spectate", GameID: gameID})
+	readWatcher()
+	readWatcher()
+	readAlice()
+	readBob()
+
+	alice.WriteJSON(Message{Type: "chat", Message: "  heck, good luck  "})
+	for _, read := range []func() Message{readAlice, readBob, readWatcher} {
+		if msg := read(); msg.Type != "chat" || msg.Username != "alice" || msg.Message != "****, good luck" {
+			t.Fatalf("Expected the filtered line, got %+v", msg)
+		}
+	}
+	bob.WriteJSON(Message{Type: "chat", Emote: EmoteNiceMove})
+	if msg := readAlice(); msg.Type != "chat" || msg.Emote != EmoteNiceMove || msg.Message != "" {
+		t.Fatalf("Expected an emote, got %+v", msg)
+	}
+	readBob()
+	readWatcher()
+
+	for _, msg := range []Message{
+		{Type: "chat", Emote: "heck"},
+		{Type: "chat", Message: "   "},
+		{Type: "chat", Message: strings.Repeat("a", maxChatLength+1)},
+	} {
+		bob.WriteJSON(msg)
+		if reply := readBob(); reply.Type != "error" {
+			t.Errorf("%+v should be refused, got %+v", msg, reply)
+		}
+	}
+	watcher.WriteJSON(Message{Type: "chat", Message: "hello"})
+	if msg := readWatcher(); msg.Type != "error" {
+		t.Errorf("Spectators cannot chat, got %+v", msg)
+	}
+
+	rec := httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/"+gameID+"/chat", nil))
+	if body := rec.Body.String(); strings.Contains(body, "heck") || !strings.Contains(body, "nice move") {
+		t.Errorf("The public chat should show only filtered lines, got %s", body)
+	}
+
+	// Moderators see what was typed, with the token
+	gs.moderatorToken = "secret"
+	req := httptest.NewRequest("GET", "/moderation/games/"+gameID+"/chat", nil)
+	rec = httptest.NewRecorder()
+	gs.handleModeration(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Moderation without the token should be 401, got %d", rec.Code)
+	}
+	var lines []moderatedLine
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	gs.handleModeration(rec, req)
+	json.NewDecoder(rec.Body).Decode(&lines)
+	if len(lines) != 2 || lines[0].Original != "heck, good luck" || lines[0].Message != "****, good luck" || lines[1].Emote != EmoteNiceMove {
+		t.Errorf("Both lines should be kept for moderators, got %+v", lines)
+	}
+	rec = httptest.NewRecorder()
+	gs.handleGames(rec, httptest.NewRequest("GET", "/games/missing/chat", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Unknown game should be 404, got %d", rec.Code)
+	}
+}
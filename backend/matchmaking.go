@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const matchInterval = time.Second
+
+// difficultyForRating picks the bot level for a player who left the choice
+// to the server.
+func difficultyForRating(rating float64) Difficulty {
+	switch {
+	case rating < 1200:
+		return Easy
+	case rating < 1450:
+		return Medium
+	case rating < 1800:
+		return Hard
+	}
+	return Perfect
+}
+
+// enqueue puts player in the matchmaking queue and tries to pair it straight
+// away. It returns the new game if one started. The caller holds gs.mutex.
+func (gs *GameServer) enqueue(player *Player) *GameState {
+	gs.queue.Add(player.Username, player.TimeControl.String(), player.Rating.Rating)
+	gs.waiting[player.Username] = player
+	gs.matchQueued()
+	if gs.waiting[player.Username] != player {
+		return gs.playerGames[player.Username]
+	}
+	player.Conn.WriteJSON(Message{Type: "waiting"})
+	log.Printf("⏳ %s waiting", player.Username)
+	return nil
+}
+
+// matchQueued starts a game for every pair the queue can make, and a bot
+// game for every player whose search window ran out. The caller holds
+// gs.mutex.
+func (gs *GameServer) matchQueued() {
+	pairs, expired := gs.queue.Match()
+	for _, pair := range pairs {
+		p1, p2 := gs.waiting[pair.A.ID], gs.waiting[pair.B.ID]
+		delete(gs.waiting, pair.A.ID)
+		delete(gs.waiting, pair.B.ID)
+		log.Printf("👥 Matching %s (%.0f) vs %s (%.0f)", p1.Username, pair.A.Rating, p2.Username, pair.B.Rating)
+		gs.createGame(p1, p2, nil, true)
+	}
+	for _, t := range expired {
+		player := gs.waiting[t.ID]
+		delete(gs.waiting, t.ID)
+		if player.Difficulty == "" {
+			player.Difficulty = difficultyForRating(t.Rating)
+		}
+		b := gs.newBot(player.Difficulty)
+		botPlayer := &Player{Username: botUsername(b), Color: Yellow}
+		log.Printf("🤖 %s joining %s", botPlayer.Username, player.Username)
+		gs.createGame(player, botPlayer, b, false)
+	}
+}
+
+// runMatchmaking matches the queue every interval as windows widen.
+func (gs *GameServer) runMatchmaking(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		gs.mutex.Lock()
+		gs.matchQueued()
+		gs.mutex.Unlock()
+	}
+}
+
+// leaveQueue takes username out of the anonymous queue. The caller holds
+// gs.mutex.
+func (gs *GameServer) leaveQueue(username string) {
+	gs.queue.Remove(username)
+	delete(gs.waiting, username)
+}
+
+// dequeue takes player out of the queue when its connection closes, unless
+// it has since queued again from another connection.
+func (gs *GameServer) dequeue(player *Player) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	if gs.waiting[player.Username] == player {
+		gs.leaveQueue(player.Username)
+	}
+}
@@ -0,0 +1,139 @@
+// Package matchmaking pairs waiting players by rating. Each player starts
+// out accepting opponents within a narrow rating window that widens the
+// longer they wait; once it grows past a limit the player is given up on
+// and handed back, so the server can offer a bot instead.
+package matchmaking
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Clock tells the queue the time. Tests substitute a fake to control how
+// long players appear to have waited.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Config sets how the search window grows. Windows are rating differences.
+type Config struct {
+	// InitialWindow is accepted as soon as a player joins.
+	InitialWindow float64
+	// WidenPerSecond is added to the window for every second waited.
+	WidenPerSecond float64
+	// MaxWindow is the widest search; a player still unmatched beyond it
+	// expires.
+	MaxWindow float64
+}
+
+// DefaultConfig reaches its limit after ten seconds.
+var DefaultConfig = Config{InitialWindow: 50, WidenPerSecond: 25, MaxWindow: 300}
+
+// Ticket is a player waiting in the queue. Only tickets in the same pool,
+// such as players who want the same time control, are matched.
+type Ticket struct {
+	ID     string
+	Pool   string
+	Rating float64
+	Joined time.Time
+}
+
+// Pair is two tickets matched with each other. A has waited longer.
+type Pair struct {
+	A, B Ticket
+}
+
+// Queue holds the waiting players. It is not safe for concurrent use.
+type Queue struct {
+	cfg     Config
+	clock   Clock
+	tickets []Ticket
+}
+
+// NewQueue returns an empty queue. A nil clock uses the system time.
+func NewQueue(cfg Config, clock Clock) *Queue {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Queue{cfg: cfg, clock: clock}
+}
+
+// Add puts a player in the queue, replacing any ticket with the same ID.
+func (q *Queue) Add(id, pool string, rating float64) Ticket {
+	q.Remove(id)
+	t := Ticket{ID: id, Pool: pool, Rating: rating, Joined: q.clock.Now()}
+	q.tickets = append(q.tickets, t)
+	return t
+}
+
+// Remove takes a player out of the queue and reports whether it was there.
+func (q *Queue) Remove(id string) bool {
+	for i, t := range q.tickets {
+		if t.ID == id {
+			q.tickets = append(q.tickets[:i], q.tickets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of waiting players.
+func (q *Queue) Len() int {
+	return len(q.tickets)
+}
+
+// Window returns the rating difference t currently accepts.
+func (q *Queue) Window(t Ticket) float64 {
+	waited := q.clock.Now().Sub(t.Joined).Seconds()
+	return q.cfg.InitialWindow + q.cfg.WidenPerSecond*waited
+}
+
+// Match pairs every player it can and removes them from the queue. Two
+// players in the same pool match when their ratings are within both of
+// their windows; the longest-waiting players choose first, taking the
+// closest rating. Players left unmatched whose window has passed the limit
+// are removed and returned as expired.
+func (q *Queue) Match() (pairs []Pair, expired []Ticket) {
+	sort.SliceStable(q.tickets, func(i, j int) bool {
+		return q.tickets[i].Joined.Before(q.tickets[j].Joined)
+	})
+	matched := make([]bool, len(q.tickets))
+	for i, a := range q.tickets {
+		if matched[i] {
+			continue
+		}
+		best, bestDiff := -1, math.Inf(1)
+		for j := i + 1; j < len(q.tickets); j++ {
+			b := q.tickets[j]
+			diff := math.Abs(a.Rating - b.Rating)
+			if matched[j] || b.Pool != a.Pool || diff > q.Window(a) || diff > q.Window(b) {
+				continue
+			}
+			if diff < bestDiff {
+				best, bestDiff = j, diff
+			}
+		}
+		if best >= 0 {
+			matched[i], matched[best] = true, true
+			pairs = append(pairs, Pair{A: a, B: q.tickets[best]})
+		}
+	}
+
+	var waiting []Ticket
+	for i, t := range q.tickets {
+		switch {
+		case matched[i]:
+		case q.Window(t) > q.cfg.MaxWindow:
+			expired = append(expired, t)
+		default:
+			waiting = append(waiting, t)
+		}
+	}
+	q.tickets = waiting
+	return pairs, expired
+}
@@ -0,0 +1,134 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestQueue() (*Queue, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return NewQueue(Config{InitialWindow: 50, WidenPerSecond: 25, MaxWindow: 300}, clock), clock
+}
+
+func TestCloseRatingsMatchImmediately(t *testing.T) {
+	q, _ := newTestQueue()
+	q.Add("alice", "", 1500)
+	q.Add("bob", "", 1540)
+
+	pairs, expired := q.Match()
+	if len(pairs) != 1 || len(expired) != 0 {
+		t.Fatalf("Match() = %v, %v; want one pair", pairs, expired)
+	}
+	if pairs[0].A.ID != "alice" || pairs[0].B.ID != "bob" {
+		t.Errorf("pair = %s vs %s, want alice vs bob", pairs[0].A.ID, pairs[0].B.ID)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after matching, want 0", q.Len())
+	}
+}
+
+func TestWindowWidensOverTime(t *testing.T) {
+	q, clock := newTestQueue()
+	q.Add("alice", "", 1500)
+	q.Add("bob", "", 1700)
+
+	if pairs, _ := q.Match(); len(pairs) != 0 {
+		t.Fatalf("200 points apart matched straight away")
+	}
+	clock.advance(5 * time.Second) // window 175
+	if pairs, _ := q.Match(); len(pairs) != 0 {
+		t.Fatalf("200 points apart matched with a 175 window")
+	}
+	clock.advance(time.Second) // window 200
+	pairs, expired := q.Match()
+	if len(pairs) != 1 || len(expired) != 0 {
+		t.Fatalf("Match() = %v, %v; want one pair once the window reaches 200", pairs, expired)
+	}
+}
+
+func TestBothWindowsMustAccept(t *testing.T) {
+	q, clock := newTestQueue()
+	q.Add("alice", "", 1500)
+	clock.advance(8 * time.Second) // alice accepts 250
+	q.Add("bob", "", 1700)         // bob accepts 50
+
+	if pairs, _ := q.Match(); len(pairs) != 0 {
+		t.Fatalf("matched although bob's window is only 50")
+	}
+	clock.advance(5 * time.Second) // bob accepts 175, alice has expired
+	pairs, expired := q.Match()
+	if len(pairs) != 0 || len(expired) != 1 || expired[0].ID != "alice" {
+		t.Fatalf("Match() = %v, %v; want alice to expire", pairs, expired)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len() = %d, want bob still waiting", q.Len())
+	}
+}
+
+func TestClosestRatingPreferred(t *testing.T) {
+	q, clock := newTestQueue()
+	q.Add("alice", "", 1500)
+	clock.advance(4 * time.Second)
+	q.Add("bob", "", 1590)
+	q.Add("carol", "", 1530)
+	clock.advance(2 * time.Second)
+
+	pairs, _ := q.Match()
+	if len(pairs) != 1 || pairs[0].B.ID != "carol" {
+		t.Fatalf("Match() = %v, want alice paired with carol", pairs)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	q, clock := newTestQueue()
+	q.Add("alice", "", 1500)
+
+	clock.advance(10 * time.Second) // window exactly 300
+	if _, expired := q.Match(); len(expired) != 0 {
+		t.Fatalf("expired at the limit, want only beyond it")
+	}
+	clock.advance(time.Second)
+	if _, expired := q.Match(); len(expired) != 1 {
+		t.Fatalf("still waiting beyond the limit")
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want expired player removed", q.Len())
+	}
+}
+
+func TestAddReplacesAndRemove(t *testing.T) {
+	q, clock := newTestQueue()
+	q.Add("alice", "", 1500)
+	clock.advance(20 * time.Second)
+	q.Add("alice", "", 1500) // rejoining restarts the wait
+
+	if _, expired := q.Match(); len(expired) != 0 || q.Len() != 1 {
+		t.Fatalf("rejoined ticket expired or duplicated")
+	}
+	if !q.Remove("alice") || q.Remove("alice") {
+		t.Errorf("Remove should succeed once")
+	}
+}
+
+func TestPoolsAreSeparate(t *testing.T) {
+	q, clock := newTestQueue()
+	q.Add("alice", "180+2", 1500)
+	q.Add("bob", "30/move", 1500)
+	if pairs, _ := q.Match(); len(pairs) != 0 {
+		t.Fatalf("players from different pools matched")
+	}
+	clock.advance(time.Second)
+	q.Add("carol", "30/move", 1520)
+	pairs, _ := q.Match()
+	if len(pairs) != 1 || pairs[0].A.ID != "bob" || pairs[0].B.ID != "carol" {
+		t.Fatalf("Match() = %v, want bob vs carol", pairs)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"connect4/engine"
+)
+
+const (
+	// maxImportSize bounds an imported game: a full game is 42 moves plus tags.
+	maxImportSize = 16 << 10
+
+	// defaultImportTTL is how long an imported game stays on the server.
+	defaultImportTTL = time.Hour
+
+	importBurst  = 10 // imports one address may make within importWindow
+	importWindow = time.Minute
+)
+
+var errTooManyImports = errors.New("too many imports - try again in a minute")
+
+var resultTags = map[string]string{
+	string(Red):    engine.ResultRed,
+	string(Yellow): engine.ResultYellow,
+	"draw":         engine.ResultDraw,
+}
+
+// recordNotation converts a game record to the engine's text record.
+func recordNotation(rec *GameRecord) *engine.Record {
+	result, ok := resultTags[rec.Result]
+	if !ok {
+		result = engine.ResultUnknown
+	}
+	tags := map[string]string{
+		"Red":         rec.Player1,
+		"Yellow":      rec.Player2,
+		"Date":        rec.StartTime.Format("2006.01.02"),
+		"Result":      result,
+		"TimeControl": rec.TimeControl,
+	}
+	if rec.TimeControl == "" {
+		tags["TimeControl"] = "-"
+	}
+	if rec.Termination != "" {
+		tags["Termination"] = rec.Termination
+	}
+	if rec.IsBot {
+		tags["Bot"] = string(rec.Difficulty)
+	}
+	return &engine.Record{Tags: tags, Moves: columns(rec.Moves)}
+}
+
+// getNotation serves GET /games/{id}/notation as a record with tags, or as
+// the bare column string with ?format=moves.
+func (gs *GameServer) getNotation(w http.ResponseWriter, r *http.Request, gameID string) {
+	rec, err := gs.loadGame(gameID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "game not found"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if r.URL.Query().Get("format") == "moves" {
+		io.WriteString(w, engine.FormatMoves(columns(rec.Moves))+"\n")
+		return
+	}
+	io.WriteString(w, recordNotation(rec).String())
+}
+
+// importGame serves POST /games/import. The body is a record or a bare
+// column string; the game becomes an analysis board that can be fetched,
+// replayed, analysed and, if unfinished, asked for hints.
+func (gs *GameServer) importGame(w http.ResponseWriter, r *http.Request) {
+	if !gs.allowFrom(gs.importLimits, r, importBurst, importWindow, time.Now()) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": errTooManyImports.Error()})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+	if err != nil || len(body) > maxImportSize {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "game text too large"})
+		return
+	}
+	notation, pos, err := engine.ParseRecord(string(body))
+	if err != nil {
+		var pe *engine.PlyError
+		resp := map[string]interface{}{"error": err.Error()}
+		if errors.As(err, &pe) {
+			resp["ply"] = pe.Ply
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	game := importedGame(notation, pos)
+	gs.mutex.Lock()
+	gs.games[game.ID] = game
+	gs.mutex.Unlock()
+	time.AfterFunc(gs.importTTL, func() { gs.expireImport(game) })
+
+	game.mutex.RLock()
+	rec := recordOf(game)
+	game.mutex.RUnlock()
+
+	log.Printf("📥 Imported %s (%d moves)", game.ID, len(game.Moves))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rec)
+}
+
+// expireImport drops an imported game once its time is up.
+func (gs *GameServer) expireImport(game *GameState) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	if gs.games[game.ID] == game {
+		delete(gs.games, game.ID)
+		log.Printf("⌛ Import %s expired", game.ID)
+	}
+}
+
+// importedGame builds an analysis board from a parsed record.
+func importedGame(notation *engine.Record, pos *engine.Position) *GameState {
+	player := func(tag string) *Player {
+		name := notation.Tags[tag]
+		if name == "" {
+			name = "?"
+		}
+		return &Player{Username: name}
+	}
+	game := &GameState{
+		ID: generateID(), Player1: player("Red"), Player2: player("Yellow"),
+		CurrentPlayer: colorOf(pos.ToMove()), StartTime: time.Now(), Imported: true,
+	}
+	game.Player1.Color, game.Player2.Color = Red, Yellow
+	if date, err := time.Parse("2006.01.02", notation.Tags["Date"]); err == nil {
+		game.StartTime = date
+	}
+	for color, tag := range resultTags {
+		if notation.Tags["Result"] == tag {
+			game.Winner = color
+			game.EndTime = &game.StartTime
+		}
+	}
+
+	game.Board = make([][]Color, ROWS)
+	for i := range game.Board {
+		game.Board[i] = make([]Color, COLS)
+	}
+	replayed := engine.NewPosition()
+	for i, col := range notation.Moves {
+		color := colorOf(replayed.ToMove())
+		row, _ := replayed.Play(col)
+		game.Board[row][col] = color
+		game.Moves = append(game.Moves, Move{Ply: i + 1, Column: col, Row: row, Color: color})
+	}
+	// Bare move strings and "*" results still end when the board says so
+	if game.Winner == "" && replayed.IsOver() {
+		game.Winner = "draw"
+		if w := replayed.Winner(); w != engine.Empty {
+			game.Winner = string(colorOf(w))
+		}
+		game.Termination = EndNormal
+		game.EndTime = &game.StartTime
+	}
+	return game
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const recentGamesLimit = 10
+
+// GameStats counts a player's results.
+type GameStats struct {
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+// RatingPoint is a player's rating after one rated game.
+type RatingPoint struct {
+	GameID string    `json:"game_id"`
+	Rating float64   `json:"rating"`
+	Time   time.Time `json:"time"`
+}
+
+// PlayerGame is a finished game from one player's side. Rating is their
+// rating after the game, for rated games only.
+type PlayerGame struct {
+	ID        string     `json:"id"`
+	Opponent  string     `json:"opponent"`
+	Color     Color      `json:"color"`
+	Result    string     `json:"result"` // "win", "loss" or "draw"
+	IsBot     bool       `json:"is_bot"`
+	Rated     bool       `json:"rated"`
+	Moves     int        `json:"moves"`
+	Rating    float64    `json:"rating,omitempty"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+}
+
+// PlayerProfile summarises a player's saved games. AverageDuration is in
+// seconds; LongestStreak is the most wins in a row.
+type PlayerProfile struct {
+	Username        string        `json:"username"`
+	Rating          float64       `json:"rating"`
+	Deviation       float64       `json:"deviation"`
+	RatingHistory   []RatingPoint `json:"rating_history"`
+	PvP             GameStats     `json:"pvp"`
+	Bot             GameStats     `json:"bot"`
+	AverageMoves    float64       `json:"average_moves"`
+	AverageDuration float64       `json:"average_duration"`
+	LongestStreak   int           `json:"longest_win_streak"`
+	RecentGames     []PlayerGame  `json:"recent_games"`
+}
+
+// handlePlayers routes the /players/ endpoints:
+//
+//	GET /players/{username}
+//	GET /players/{username}/games?status=your_turn
+func (gs *GameServer) handlePlayers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/players/"), "/"), "/")
+	switch {
+	case r.Method != http.MethodGet || parts[0] == "":
+	case len(parts) == 1:
+		gs.getPlayer(w, parts[0])
+		return
+	case len(parts) == 2 && parts[1] == "games":
+		gs.getPlayerGames(w, r, parts[0])
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+}
+
+func (gs *GameServer) getPlayer(w http.ResponseWriter, username string) {
+	var games []PlayerGame
+	var err error
+	if gs.db != nil {
+		games, err = gs.playerGamesOf(username)
+	}
+	if err != nil {
+		log.Println("❌ Error loading player:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "could not load player"})
+		return
+	}
+	if len(games) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "player not found"})
+		return
+	}
+	r := gs.ratingOf(username)
+	p := buildProfile(username, games)
+	p.Rating, p.Deviation = math.Round(r.Rating), math.Round(r.Deviation)
+	json.NewEncoder(w).Encode(p)
+}
+
+// playerGamesOf loads every saved game of username, oldest first.
+func (gs *GameServer) playerGamesOf(username string) ([]PlayerGame, error) {
+	rows, err := gs.db.Query(`
+		SELECT g.id, g.player1, g.player2, g.winner, g.start_time, g.end_time, g.is_bot, g.rated,
+			g.player1_rating, g.player2_rating, COALESCE(m.n, 0)
+		FROM games g
+		LEFT JOIN (SELECT game_id, COUNT(*) AS n FROM moves GROUP BY game_id) m ON m.game_id = g.id
+		WHERE (g.player1 = $1 OR g.player2 = $1) AND g.end_time IS NOT NULL
+		ORDER BY g.end_time, g.id
+	`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []PlayerGame
+	for rows.Next() {
+		var pg PlayerGame
+		var player1, player2 string
+		var winner sql.NullString
+		var rated sql.NullBool
+		var rating1, rating2 sql.NullFloat64
+		err := rows.Scan(&pg.ID, &player1, &player2, &winner, &pg.StartTime, &pg.EndTime, &pg.IsBot, &rated,
+			&rating1, &rating2, &pg.Moves)
+		if err != nil {
+			return nil, err
+		}
+		pg.Rated = rated.Bool
+		pg.Color, pg.Opponent, pg.Rating = Red, player2, rating1.Float64
+		if player1 != username {
+			pg.Color, pg.Opponent, pg.Rating = Yellow, player1, rating2.Float64
+		}
+		switch winner.String {
+		case "":
+			pg.Result = "draw"
+		case username:
+			pg.Result = "win"
+		default:
+			pg.Result = "loss"
+		}
+		pg.Rating = math.Round(pg.Rating)
+		games = append(games, pg)
+	}
+	return games, rows.Err()
+}
+
+// buildProfile computes a profile from a player's games, oldest first. The
+// current rating is left to the caller.
+func buildProfile(username string, games []PlayerGame) *PlayerProfile {
+	p := &PlayerProfile{Username: username, RatingHistory: []RatingPoint{}, RecentGames: []PlayerGame{}}
+	var moves, seconds float64
+	var timed, streak int
+	for _, g := range games {
+		stats := &p.PvP
+		if g.IsBot {
+			stats = &p.Bot
+		}
+		switch g.Result {
+		case "win":
+			stats.Wins++
+			streak++
+			if streak > p.LongestStreak {
+				p.LongestStreak = streak
+			}
+		case "loss":
+			stats.Losses++
+			streak = 0
+		default:
+			stats.Draws++
+			streak = 0
+		}
+
+		moves += float64(g.Moves)
+		if g.EndTime != nil {
+			seconds += g.EndTime.Sub(g.StartTime).Seconds()
+			timed++
+		}
+		if g.Rated && g.Rating != 0 {
+			p.RatingHistory = append(p.RatingHistory, RatingPoint{GameID: g.ID, Rating: g.Rating, Time: g.StartTime})
+			if g.EndTime != nil {
+				p.RatingHistory[len(p.RatingHistory)-1].Time = *g.EndTime
+			}
+		}
+	}
+	if len(games) > 0 {
+		p.AverageMoves = math.Round(moves/float64(len(games))*10) / 10
+	}
+	if timed > 0 {
+		p.AverageDuration = math.Round(seconds/float64(timed)*10) / 10
+	}
+	for i := len(games) - 1; i >= 0 && len(p.RecentGames) < recentGamesLimit; i-- {
+		p.RecentGames = append(p.RecentGames, games[i])
+	}
+	return p
+}
@@ -0,0 +1,113 @@
+// Package rating implements the Glicko-2 rating system. A player's strength
+// is a rating with a deviation, how uncertain it is, and a volatility, how
+// erratic their results have been. The deviation shrinks as games are played
+// and grows again while a player is inactive.
+//
+// See Glickman, "Example of the Glicko-2 system" (2013).
+package rating
+
+import "math"
+
+// Defaults for a player with no games.
+const (
+	DefaultRating     = 1500
+	DefaultDeviation  = 350
+	DefaultVolatility = 0.06
+)
+
+const (
+	scale   = 173.7178 // between the Glicko and Glicko-2 scales
+	tau     = 0.5      // constrains how fast volatility changes
+	epsilon = 0.000001 // convergence tolerance for the volatility
+)
+
+// Rating is a player's Glicko-2 rating on the familiar 1500-based scale.
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// Default returns the rating of a new player.
+func Default() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Result is one game against an opponent. Score is 1 for a win, 0.5 for a
+// draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Decay widens the deviation for periods rating periods without games. It
+// never exceeds the deviation of a new player.
+func (r Rating) Decay(periods float64) Rating {
+	if periods <= 0 {
+		return r
+	}
+	phi := r.Deviation / scale
+	phi = math.Sqrt(phi*phi + periods*r.Volatility*r.Volatility)
+	r.Deviation = math.Min(phi*scale, DefaultDeviation)
+	return r
+}
+
+// Update returns the rating after a rating period with the given results.
+// With no results only the deviation changes, as for one idle period.
+func (r Rating) Update(results ...Result) Rating {
+	if len(results) == 0 {
+		return r.Decay(1)
+	}
+	mu, phi := (r.Rating-DefaultRating)/scale, r.Deviation/scale
+
+	var vInv, sum float64
+	for _, res := range results {
+		muJ, phiJ := (res.Opponent.Rating-DefaultRating)/scale, res.Opponent.Deviation/scale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (res.Score - e)
+	}
+	v := 1 / vInv
+	sigma := volatility(phi, v, v*sum, r.Volatility)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+	return Rating{Rating: mu*scale + DefaultRating, Deviation: phi * scale, Volatility: sigma}
+}
+
+// volatility finds the new volatility by the Illinois algorithm, step 5 of
+// Glickman's paper.
+func volatility(phi, v, delta, sigma float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
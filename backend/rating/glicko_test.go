@@ -0,0 +1,70 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+// TestGlickmanExample reproduces the worked example from Glickman's paper.
+func TestGlickmanExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := player.Update(
+		Result{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		Result{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		Result{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	)
+	if !near(got.Rating, 1464.06, 0.01) || !near(got.Deviation, 151.52, 0.01) || !near(got.Volatility, 0.05999, 0.00001) {
+		t.Errorf("Update() = %+v, want 1464.06 / 151.52 / 0.05999", got)
+	}
+}
+
+func TestSingleGame(t *testing.T) {
+	a, b := Default(), Default()
+	winner := a.Update(Result{Opponent: b, Score: 1})
+	loser := b.Update(Result{Opponent: a, Score: 0})
+	if winner.Rating <= DefaultRating || loser.Rating >= DefaultRating {
+		t.Fatalf("winner %v, loser %v", winner.Rating, loser.Rating)
+	}
+	if !near(winner.Rating-DefaultRating, DefaultRating-loser.Rating, 1e-6) {
+		t.Errorf("equal players should move by the same amount, got %v and %v", winner.Rating, loser.Rating)
+	}
+	if winner.Deviation >= DefaultDeviation {
+		t.Errorf("deviation should shrink after a game, got %v", winner.Deviation)
+	}
+
+	drawn := a.Update(Result{Opponent: b, Score: 0.5})
+	if !near(drawn.Rating, DefaultRating, 1e-6) {
+		t.Errorf("a draw between equals should not move the rating, got %v", drawn.Rating)
+	}
+}
+
+func TestUpsetMovesMore(t *testing.T) {
+	weak := Rating{Rating: 1300, Deviation: 80, Volatility: DefaultVolatility}
+	strong := Rating{Rating: 1700, Deviation: 80, Volatility: DefaultVolatility}
+	upset := weak.Update(Result{Opponent: strong, Score: 1}).Rating - weak.Rating
+	expected := strong.Update(Result{Opponent: weak, Score: 1}).Rating - strong.Rating
+	if upset <= expected {
+		t.Errorf("beating a stronger player should gain more: %v vs %v", upset, expected)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	r := Rating{Rating: 1600, Deviation: 50, Volatility: DefaultVolatility}
+	if got := r.Decay(0); got != r {
+		t.Errorf("Decay(0) = %+v, want unchanged", got)
+	}
+	month := r.Decay(30)
+	if month.Deviation <= r.Deviation || month.Rating != r.Rating {
+		t.Errorf("Decay(30) = %+v, want a wider deviation and the same rating", month)
+	}
+	if got := r.Decay(1e6).Deviation; got != DefaultDeviation {
+		t.Errorf("deviation should be capped at %v, got %v", DefaultDeviation, got)
+	}
+	if got := r.Update(); !near(got.Deviation, r.Decay(1).Deviation, 1e-9) {
+		t.Errorf("an empty period should only decay, got %+v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+
+	"connect4/rating"
+)
+
+// ratingPeriod is how long a player must sit out for their rating deviation
+// to widen by one Glicko-2 period. Every rated game is a period of its own.
+const ratingPeriod = 24 * time.Hour
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadRating reads username's rating as of now, its deviation widened for
+// the time since their last rated game. Unrated players get the default.
+// With lock set the row stays locked until the transaction ends.
+func loadRating(q rowQuerier, username string, now time.Time, lock bool) (rating.Rating, error) {
+	query := `SELECT rating, deviation, volatility, last_played FROM ratings WHERE username = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	var r rating.Rating
+	var last time.Time
+	err := q.QueryRow(query, username).Scan(&r.Rating, &r.Deviation, &r.Volatility, &last)
+	if err == sql.ErrNoRows {
+		return rating.Default(), nil
+	}
+	if err != nil {
+		return rating.Rating{}, err
+	}
+	return r.Decay(float64(now.Sub(last)) / float64(ratingPeriod)), nil
+}
+
+// ratingOf returns username's current rating, or the default if it cannot
+// be read.
+func (gs *GameServer) ratingOf(username string) rating.Rating {
+	if gs.db == nil {
+		return rating.Default()
+	}
+	r, err := loadRating(gs.db, username, time.Now(), false)
+	if err != nil {
+		log.Println("❌ Error loading rating:", err)
+		return rating.Default()
+	}
+	return r
+}
+
+// newRatings rates one game between red and yellow. winner is the winning
+// color or "draw".
+func newRatings(red, yellow rating.Rating, winner string) (rating.Rating, rating.Rating) {
+	score := 0.5
+	switch Color(winner) {
+	case Red:
+		score = 1
+	case Yellow:
+		score = 0
+	}
+	return red.Update(rating.Result{Opponent: yellow, Score: score}),
+		yellow.Update(rating.Result{Opponent: red, Score: 1 - score})
+}
+
+// rateGame updates both players' ratings for a finished rated game, inside
+// the transaction that saves it, and records them on the game's row.
+func rateGame(tx *sql.Tx, game *GameState) error {
+	now := time.Now()
+	if game.EndTime != nil {
+		now = *game.EndTime
+	}
+
+	// Lock the rows in name order so two saves cannot deadlock
+	names := []string{game.Player1.Username, game.Player2.Username}
+	sort.Strings(names)
+	old := make(map[string]rating.Rating)
+	for _, name := range names {
+		r, err := loadRating(tx, name, now, true)
+		if err != nil {
+			return err
+		}
+		old[name] = r
+	}
+
+	red, yellow := newRatings(old[game.Player1.Username], old[game.Player2.Username], game.Winner)
+	for name, r := range map[string]rating.Rating{game.Player1.Username: red, game.Player2.Username: yellow} {
+		_, err := tx.Exec(`
+			INSERT INTO ratings (username, rating, deviation, volatility, games, last_played)
+			VALUES ($1, $2, $3, $4, 1, $5)
+			ON CONFLICT (username) DO UPDATE SET
+				rating = EXCLUDED.rating, deviation = EXCLUDED.deviation,
+				volatility = EXCLUDED.volatility, games = ratings.games + 1,
+				last_played = EXCLUDED.last_played
+		`, name, r.Rating, r.Deviation, r.Volatility, now)
+		if err != nil {
+			return err
+		}
+		log.Printf("📈 %s: %.0f → %.0f", name, old[name].Rating, r.Rating)
+	}
+	_, err := tx.Exec(`UPDATE games SET player1_rating = $1, player2_rating = $2 WHERE id = $3`, red.Rating, yellow.Rating, game.ID)
+	return err
+}
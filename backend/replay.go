@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// replayInterval is the delay between moves at speed 1.
+const replayInterval = time.Second
+
+const (
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 16
+)
+
+// replay streams a recorded game to one connection as the same "move" and
+// "game_over" messages a live game sends, so the normal board can show it.
+type replay struct {
+	conn  *Conn
+	rec   *GameRecord
+	cmds  chan Message
+	done  chan struct{}
+	ply   int
+	speed float64
+}
+
+// startReplay loads a game and starts streaming it from the first move.
+func (gs *GameServer) startReplay(conn *Conn, gameID string, speed float64) (*replay, error) {
+	rec, err := gs.loadGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	rp := &replay{
+		conn: conn, rec: rec,
+		cmds:  make(chan Message),
+		done:  make(chan struct{}),
+		speed: clampSpeed(speed),
+	}
+	log.Printf("📼 Replaying %s (%d moves, %gx)", rec.ID, len(rec.Moves), rp.speed)
+	go rp.run()
+	return rp, nil
+}
+
+func clampSpeed(speed float64) float64 {
+	switch {
+	case speed == 0:
+		return 1
+	case speed < minReplaySpeed:
+		return minReplaySpeed
+	case speed > maxReplaySpeed:
+		return maxReplaySpeed
+	}
+	return speed
+}
+
+// control passes a replay_* message to the stream. It returns false if the
+// replay has already stopped.
+func (rp *replay) control(msg Message) bool {
+	select {
+	case rp.cmds <- msg:
+		return true
+	case <-rp.done:
+		return false
+	}
+}
+
+// stop ends the stream. It is safe to call more than once.
+func (rp *replay) stop() {
+	rp.control(Message{Type: "replay_stop"})
+}
+
+func (rp *replay) run() {
+	defer close(rp.done)
+	ticker := time.NewTicker(rp.interval())
+	defer ticker.Stop()
+
+	paused := false
+	rp.conn.WriteJSON(Message{Type: "replay_start", GameID: rp.rec.ID, Game: rp.rec, Speed: rp.speed})
+	rp.sendFrame()
+	for {
+		select {
+		case cmd := <-rp.cmds:
+			switch cmd.Type {
+			case "replay_pause":
+				paused = true
+			case "replay_resume":
+				paused = false
+				ticker.Reset(rp.interval())
+			case "replay_seek":
+				rp.ply = cmd.Ply
+				if rp.ply < 0 {
+					rp.ply = 0
+				} else if rp.ply > len(rp.rec.Moves) {
+					rp.ply = len(rp.rec.Moves)
+				}
+				rp.sendFrame()
+				ticker.Reset(rp.interval())
+			case "replay_speed":
+				rp.speed = clampSpeed(cmd.Speed)
+				ticker.Reset(rp.interval())
+			case "replay_stop":
+				return
+			}
+		case <-ticker.C:
+			if paused || rp.ply >= len(rp.rec.Moves) {
+				continue
+			}
+			rp.ply++
+			rp.sendFrame()
+		}
+	}
+}
+
+func (rp *replay) interval() time.Duration {
+	return time.Duration(float64(replayInterval) / rp.speed)
+}
+
+// sendFrame sends the board after the current ply, and the result once the
+// last move has been shown.
+func (rp *replay) sendFrame() {
+	board := make([][]Color, ROWS)
+	for i := range board {
+		board[i] = make([]Color, COLS)
+	}
+	next := Red
+	msg := Message{Type: "move", GameID: rp.rec.ID, Ply: rp.ply, Board: board}
+	for _, m := range rp.rec.Moves[:rp.ply] {
+		board[m.Row][m.Column] = m.Color
+		msg.Column = m.Column
+		next = Red
+		if m.Color == Red {
+			next = Yellow
+		}
+	}
+	msg.CurrentPlayer = next
+	rp.conn.WriteJSON(msg)
+
+	if rp.ply < len(rp.rec.Moves) || rp.rec.Result == "" {
+		return
+	}
+	over := Message{Type: "game_over", GameID: rp.rec.ID, Board: board, Winner: rp.rec.Result}
+	if pos, err := positionFromBoard(board, next); err == nil {
+		over.WinLine = pos.WinLine()
+	}
+	rp.conn.WriteJSON(over)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	errNoDrawOffer    = errors.New("no draw offer to answer")
+	errDrawOffered    = errors.New("you have already offered a draw")
+	errGameNotOver    = errors.New("game is not over")
+	errOpponentLeft   = errors.New("your opponent has left")
+	errRematchOffered = errors.New("you have already asked for a rematch")
+)
+
+// seatOf returns username's player in game, or nil if they are not playing
+// it. The seats never change once the game is created.
+func seatOf(game *GameState, username string) *Player {
+	switch {
+	case game.Player1.Username == username:
+		return game.Player1
+	case game.Player2 != nil && game.Player2.Username == username:
+		return game.Player2
+	}
+	return nil
+}
+
+// currentGame returns the game username is playing: game, unless it is over
+// and they have moved on, for example to a rematch their opponent started.
+func (gs *GameServer) currentGame(game *GameState, username string) *GameState {
+	if game != nil {
+		game.mutex.RLock()
+		over := game.Winner != ""
+		game.mutex.RUnlock()
+		if !over {
+			return game
+		}
+	}
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+	if g := gs.playerGames[username]; g != nil {
+		return g
+	}
+	return game
+}
+
+// resign ends the game as a loss for username, whoever's turn it is.
+func (gs *GameServer) resign(game *GameState, username string) error {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	seat := seatOf(game, username)
+	if seat == nil {
+		return errNotYourGame
+	}
+	if game.Winner != "" {
+		return errFinished
+	}
+	log.Printf("🏳 %s resigned", username)
+	gs.endGame(game, string(gs.getOpponent(game, seat).Color), EndResigned, nil)
+	return nil
+}
+
+// offerDraw offers the opponent a draw. The offer stands until it is
+// answered or the opponent moves; offering back accepts it. The bot always
+// declines.
+func (gs *GameServer) offerDraw(game *GameState, username string) error {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	seat := seatOf(game, username)
+	if seat == nil {
+		return errNotYourGame
+	}
+	if game.Winner != "" {
+		return errFinished
+	}
+	opponent := gs.getOpponent(game, seat)
+	switch game.drawOffer {
+	case seat.Color:
+		return errDrawOffered
+	case opponent.Color:
+		gs.agreeDraw(game)
+		return nil
+	}
+
+	if game.IsBot {
+		if seat.Conn != nil {
+			seat.Conn.WriteJSON(Message{Type: "draw_declined", Username: opponent.Username})
+		}
+		return nil
+	}
+	game.drawOffer = seat.Color
+	log.Printf("🤝 %s offered a draw", username)
+	if opponent.Conn != nil {
+		opponent.Conn.WriteJSON(Message{Type: "draw_offered", Username: username})
+	}
+	return nil
+}
+
+// answerDraw accepts or declines the opponent's draw offer.
+func (gs *GameServer) answerDraw(game *GameState, username string, accept bool) error {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	seat := seatOf(game, username)
+	if seat == nil {
+		return errNotYourGame
+	}
+	opponent := gs.getOpponent(game, seat)
+	if game.Winner != "" || game.drawOffer != opponent.Color {
+		return errNoDrawOffer
+	}
+	if accept {
+		gs.agreeDraw(game)
+		return nil
+	}
+	game.drawOffer = ""
+	log.Printf("🤝 %s declined the draw", username)
+	if opponent.Conn != nil {
+		opponent.Conn.WriteJSON(Message{Type: "draw_declined", Username: username})
+	}
+	return nil
+}
+
+// agreeDraw ends the game drawn by agreement. The caller holds game.mutex.
+func (gs *GameServer) agreeDraw(game *GameState) {
+	game.drawOffer = ""
+	log.Println("🤝 Draw agreed")
+	gs.endGame(game, "draw", EndAgreedDraw, nil)
+}
+
+// rematch asks for a new game against the same opponent once game is over.
+// When both players have asked, or straight away against the bot, it starts
+// the game on the same connections and time control with the colours
+// swapped, skipping the queue, and returns it. The bot always plays yellow,
+// so bot rematches keep the colours.
+func (gs *GameServer) rematch(game *GameState, username string) (*GameState, error) {
+	game.mutex.Lock()
+	seat := seatOf(game, username)
+	if seat == nil {
+		game.mutex.Unlock()
+		return nil, errNotYourGame
+	}
+	if game.Winner == "" {
+		game.mutex.Unlock()
+		return nil, errGameNotOver
+	}
+	opponent := gs.getOpponent(game, seat)
+	if !game.IsBot {
+		if opponent.Conn == nil || opponent.Disconnected {
+			game.mutex.Unlock()
+			return nil, errOpponentLeft
+		}
+		if game.rematchOffer == seat.Color {
+			game.mutex.Unlock()
+			return nil, errRematchOffered
+		}
+		if game.rematchOffer != opponent.Color {
+			game.rematchOffer = seat.Color
+			log.Printf("🔁 %s asked for a rematch", username)
+			opponent.Conn.WriteJSON(Message{Type: "rematch_offered", Username: username})
+			game.mutex.Unlock()
+			return nil, nil
+		}
+	}
+	game.rematchOffer = ""
+	// The new game gets its own seats; the old ones belong to the old game
+	red, yellow := rematchSeat(game.Player2), rematchSeat(game.Player1)
+	if game.IsBot {
+		red, yellow = rematchSeat(game.Player1), rematchSeat(game.Player2)
+	}
+	tc, rated, difficulty := game.TimeControl, game.Rated, game.Difficulty
+	game.mutex.Unlock()
+
+	red.TimeControl = tc
+	red.Rating = gs.ratingOf(red.Username)
+	if !game.IsBot {
+		yellow.Rating = gs.ratingOf(yellow.Username)
+	}
+
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	log.Printf("🔁 Rematch: %s vs %s", red.Username, yellow.Username)
+	if game.IsBot {
+		return gs.createGame(red, yellow, gs.newBot(difficulty), false), nil
+	}
+	return gs.createGame(red, yellow, nil, rated), nil
+}
+
+// rematchSeat copies a player's seat for a rematch. The caller holds
+// game.mutex.
+func rematchSeat(p *Player) *Player {
+	return &Player{Username: p.Username, Conn: p.Conn, LastSeen: time.Now(), Difficulty: p.Difficulty, Rating: p.Rating}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRoomTimeout = 10 * time.Minute
+
+	// roomCodeAlphabet leaves out letters and digits that are easy to
+	// confuse when a code is read out loud: 0/O and 1/I/L.
+	roomCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	roomCodeLength   = 6
+)
+
+var (
+	errRoomNotFound = errors.New("room not found or expired")
+	errOwnRoom      = errors.New("you cannot join your own room")
+)
+
+// Room is a private game waiting for the invited player. Rooms never fall
+// back to the bot; they expire instead.
+type Room struct {
+	Code    string
+	Host    *Player
+	Expires time.Time
+	timer   *time.Timer
+}
+
+func generateRoomCode() string {
+	b := make([]byte, roomCodeLength)
+	for i := range b {
+		b[i] = roomCodeAlphabet[rand.Intn(len(roomCodeAlphabet))]
+	}
+	return string(b)
+}
+
+// createRoom opens a private room for host and sends it the code to share.
+func (gs *GameServer) createRoom(host *Player) *Room {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	code := generateRoomCode()
+	for gs.rooms[code] != nil {
+		code = generateRoomCode()
+	}
+	room := &Room{Code: code, Host: host, Expires: time.Now().Add(gs.roomTimeout)}
+	room.timer = time.AfterFunc(gs.roomTimeout, func() { gs.expireRoom(room) })
+	gs.rooms[code] = room
+
+	host.Conn.WriteJSON(Message{Type: "room_created", RoomCode: code, Message: "Share this code with your opponent"})
+	log.Printf("🚪 %s opened room %s", host.Username, code)
+	return room
+}
+
+// joinRoom starts the game in a room. The host plays red.
+func (gs *GameServer) joinRoom(player *Player, code string) (*GameState, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	room := gs.rooms[code]
+	if room == nil {
+		return nil, errRoomNotFound
+	}
+	if room.Host.Username == player.Username {
+		return nil, errOwnRoom
+	}
+	room.timer.Stop()
+	delete(gs.rooms, code)
+	log.Printf("🚪 %s joined room %s", player.Username, code)
+	return gs.createGame(room.Host, player, nil, false), nil
+}
+
+func (gs *GameServer) expireRoom(room *Room) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	if gs.rooms[room.Code] != room {
+		return
+	}
+	delete(gs.rooms, room.Code)
+	if room.Host.Conn != nil {
+		room.Host.Conn.WriteJSON(Message{Type: "room_expired", RoomCode: room.Code})
+	}
+	log.Printf("⌛ Room %s expired", room.Code)
+}
+
+// closeRoom drops a room whose host went away.
+func (gs *GameServer) closeRoom(room *Room) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	if gs.rooms[room.Code] == room {
+		room.timer.Stop()
+		delete(gs.rooms, room.Code)
+		log.Printf("🚪 Room %s closed", room.Code)
+	}
+}
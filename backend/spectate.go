@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+var (
+	errGameNotFound = errors.New("game not found")
+	errGameOver     = errors.New("game is over - use replay to watch it")
+)
+
+// spectate subscribes conn to a live game's broadcasts and sends it the
+// current position. Spectators receive every move, game_over and
+// game_forfeited message but cannot play.
+func (gs *GameServer) spectate(conn *Conn, gameID string) (*GameState, error) {
+	gs.mutex.RLock()
+	game := gs.games[gameID]
+	gs.mutex.RUnlock()
+	if game == nil || game.Imported {
+		return nil, errGameNotFound
+	}
+
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.Winner != "" {
+		return nil, errGameOver
+	}
+	if game.Spectators == nil {
+		game.Spectators = make(map[*Conn]bool)
+	}
+	game.Spectators[conn] = true
+	log.Printf("👀 Spectator joined %s (%d watching)", game.ID, len(game.Spectators))
+
+	conn.WriteJSON(Message{
+		Type: "spectating", GameID: game.ID, Game: recordOf(game),
+		Board: game.Board, CurrentPlayer: game.CurrentPlayer, Spectators: len(game.Spectators),
+	})
+	gs.broadcastSpectatorCount(game)
+	return game, nil
+}
+
+// unspectate removes conn from a game's spectators.
+func (gs *GameServer) unspectate(conn *Conn, game *GameState) {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if !game.Spectators[conn] {
+		return
+	}
+	delete(game.Spectators, conn)
+	log.Printf("👀 Spectator left %s (%d watching)", game.ID, len(game.Spectators))
+	if game.Winner == "" {
+		gs.broadcastSpectatorCount(game)
+	}
+}
+
+// sendSpectators sends msg to everyone watching the game. The caller holds
+// game.mutex.
+func (gs *GameServer) sendSpectators(game *GameState, msg Message) {
+	for conn := range game.Spectators {
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("❌ Error sending to spectator: %v", err)
+		}
+	}
+}
+
+// broadcastSpectatorCount tells players and spectators how many are
+// watching. The caller holds game.mutex.
+func (gs *GameServer) broadcastSpectatorCount(game *GameState) {
+	msg := Message{Type: "spectators", GameID: game.ID, Spectators: len(game.Spectators)}
+	for _, p := range []*Player{game.Player1, game.Player2} {
+		if p != nil && p.Conn != nil && !p.Disconnected {
+			p.Conn.WriteJSON(msg)
+		}
+	}
+	gs.sendSpectators(game, msg)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+var (
+	errUndoRated     = errors.New("take-backs are not allowed in rated games")
+	errNothingToUndo = errors.New("you have no move to take back")
+	errUndoRequested = errors.New("you have already asked for a take-back")
+	errNoUndoRequest = errors.New("no take-back request to answer")
+)
+
+// requestUndo asks to take back username's last move. The bot grants it at
+// once, taking back its own reply too; a human opponent has to accept.
+func (gs *GameServer) requestUndo(game *GameState, username string) error {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	seat := seatOf(game, username)
+	if seat == nil {
+		return errNotYourGame
+	}
+	if game.Winner != "" {
+		return errFinished
+	}
+	if game.Rated {
+		return errUndoRated
+	}
+	if undoPlies(game, seat.Color) == 0 {
+		return errNothingToUndo
+	}
+	if game.IsBot {
+		gs.cancelBotTurn(game)
+		gs.takeBack(game, seat.Color)
+		return nil
+	}
+
+	switch opponent := gs.getOpponent(game, seat); game.undoRequest {
+	case seat.Color:
+		return errUndoRequested
+	case opponent.Color:
+		// Asking back grants the standing request, as with draw offers
+		gs.takeBack(game, opponent.Color)
+	default:
+		game.undoRequest = seat.Color
+		log.Printf("↩ %s asked for a take-back", username)
+		if opponent.Conn != nil {
+			opponent.Conn.WriteJSON(Message{Type: "undo_requested", Username: username})
+		}
+	}
+	return nil
+}
+
+// answerUndo grants or refuses the opponent's take-back request.
+func (gs *GameServer) answerUndo(game *GameState, username string, accept bool) error {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	seat := seatOf(game, username)
+	if seat == nil {
+		return errNotYourGame
+	}
+	opponent := gs.getOpponent(game, seat)
+	if game.Winner != "" || game.undoRequest != opponent.Color {
+		return errNoUndoRequest
+	}
+	if accept {
+		gs.takeBack(game, opponent.Color)
+		return nil
+	}
+	game.undoRequest = ""
+	log.Printf("↩ %s refused the take-back", username)
+	if opponent.Conn != nil {
+		opponent.Conn.WriteJSON(Message{Type: "undo_declined", Username: username})
+	}
+	return nil
+}
+
+// undoPlies returns how many moves must come off the board for color to
+// play its last move again: one if it is the opponent's turn, or two if the
+// opponent has already replied. It is zero if color has not moved yet. The
+// caller holds game.mutex.
+func undoPlies(game *GameState, color Color) int {
+	plies := 1
+	if game.CurrentPlayer == color {
+		plies = 2
+	}
+	if len(game.Moves) < plies {
+		return 0
+	}
+	return plies
+}
+
+// takeBack removes moves until color is to play its last move again and
+// tells everyone watching. Time already spent stays spent. The caller holds
+// game.mutex.
+func (gs *GameServer) takeBack(game *GameState, color Color) {
+	n := len(game.Moves) - undoPlies(game, color)
+	for _, m := range game.Moves[n:] {
+		game.Board[m.Row][m.Column] = Empty
+	}
+	game.Moves = game.Moves[:n]
+	game.CurrentPlayer = color
+	game.drawOffer, game.undoRequest = "", ""
+	gs.startTurnClock(game, color)
+	if game.TimeControl.Correspondence {
+		gs.saveGame(game)
+	}
+	log.Printf("↩ Took back to ply %d", n)
+
+	msg := Message{Type: "undo", Board: game.Board, CurrentPlayer: color, Ply: n, Clock: clocks(game)}
+	for _, p := range []*Player{game.Player1, game.Player2} {
+		if p != nil && p.Conn != nil {
+			p.Conn.WriteJSON(msg)
+		}
+	}
+	gs.sendSpectators(game, msg)
+}